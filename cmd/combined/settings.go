@@ -1,7 +0,0 @@
-package main
-
-func getIp() string {
-	var AntivirusIP = "http://127.0.0.1"
-	return AntivirusIP
-}
-
@@ -1,11 +0,0 @@
-package main
-
-func getTimeOutDlp() int {
-	var time = 1
-	return time
-}
-
-func getTimeOutAntivirus() int {
-	var time = 1
-	return time
-}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"dlpagent/internal/check"
+	"dlpagent/internal/config"
+	"dlpagent/internal/summary"
+)
+
+// runCheck implements "check": the named checks, or all enabled ones, run once
+func runCheck(cfg *config.Config, args []string) int {
+	ids := check.Builtin(cfg, check.Env{}).IDs()
+	for _, name := range args {
+		if name != "all" && !slices.Contains(ids, name) {
+			fmt.Fprintf(os.Stderr, "Error: unknown check %q, expected %s or all\n", name, strings.Join(ids, ", "))
+			return 2
+		}
+	}
+
+	if err := initServerClients(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	return runChecks(cfg, args)
+}
+
+// runChecks runs checks a single time, prints a summary and returns the exit code.
+// Named checks always run; without names or with "all" the enabled checks run.
+func runChecks(cfg *config.Config, names []string) int {
+	policy, err := summary.ParsePolicy(cfg.Run.FailOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	// SIGINT and SIGTERM stop the running check; its partial results are reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The server settings may disable checks; they are optional here
+	s, err := getSettings(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Failed to get settings: %v\n", err)
+	}
+
+	all := len(names) == 0 || slices.Contains(names, "all")
+	var result summary.Summary
+	for _, c := range registry.Checks() {
+		if !all && !slices.Contains(names, c.ID()) {
+			continue
+		}
+		if all {
+			if _, enabled := s.Schedule(c.ID(), 0, c.Config().Enabled); !enabled {
+				fmt.Printf("Skipping %s check (%s is disabled)\n", c.ID(), c.ID())
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		result.Add(runRegisteredCheck(ctx, cfg, c).Outcomes...)
+	}
+
+	// Waiting results are retried by the next run, not by this process
+	queue := uploadQueue.Status()
+	queue.NextRetry = nil
+	printQueueStatus(queue)
+	result.Print(os.Stdout, policy)
+	return result.ExitCode(policy)
+}
+
+// runRegisteredCheck runs a check once, within timeouts.check, and uploads its results to the dashboard
+func runRegisteredCheck(ctx context.Context, cfg *config.Config, c check.Check) *check.Result {
+	runCtx := ctx
+	if cfg.Timeouts.Check > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cfg.Timeouts.Check)
+		defer cancel()
+	}
+	result := c.Run(runCtx)
+
+	// send data to dashboard
+	sendDashboardResult(ctx, c)
+	return result
+}
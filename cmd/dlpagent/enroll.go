@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/enroll"
+	"dlpagent/internal/seal"
+)
+
+func enrollCommand() *command {
+	var force bool
+
+	return &command{
+		name:    "enroll",
+		args:    "[TOKEN]",
+		summary: "Exchange a one-time enrollment token for the agent identity on the server",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&force, "force", false, "Enroll again even if the agent already has an identity")
+		},
+		run: func(cfg *config.Config, args []string) int {
+			if len(args) > 1 {
+				fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", args[1:])
+				return 2
+			}
+			token := cfg.Agent.EnrollToken
+			if len(args) == 1 {
+				token = args[0]
+			}
+			if token == "" {
+				fmt.Fprintln(os.Stderr, "Error: no enrollment token; pass it as an argument or set agent.enroll_token")
+				return 2
+			}
+
+			current, err := cfg.Identity()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 2
+			}
+			if current != nil && !force {
+				fmt.Fprintf(os.Stderr, "Error: the agent is already enrolled as %s; use -force to enroll again\n", current.AgentID)
+				return 2
+			}
+			if current != nil && cfg.Encryption.KeySource == seal.SourceEnrollment {
+				fmt.Fprintln(os.Stderr, "Warning: results encrypted with the key of the current enrollment can no longer be read after enrolling again")
+			}
+
+			id, err := enrollAgent(cfg, token)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 3
+			}
+			fmt.Printf("✅ Enrolled as %s (%s authentication), identity saved to %s\n", id.AgentID, id.Auth, cfg.Agent.IdentityFile)
+			return 0
+		},
+	}
+}
+
+// enrollAgent exchanges token for the agent identity and saves it
+func enrollAgent(cfg *config.Config, token string) (*enroll.Identity, error) {
+	client, err := cfg.EnrollmentHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	id, err := enroll.Enroll(context.Background(), client, cfg.Server.URL, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := id.Save(cfg.Agent.IdentityFile); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// autoEnroll enrolls the agent with agent.enroll_token when it has no identity yet
+func autoEnroll(cfg *config.Config) error {
+	if cfg.Agent.EnrollToken == "" {
+		return nil
+	}
+	if id, err := cfg.Identity(); err != nil || id != nil {
+		return err
+	}
+	id, err := enrollAgent(cfg, cfg.Agent.EnrollToken)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Enrolled as %s (%s authentication)\n", id.AgentID, id.Auth)
+	return nil
+}
+
+// renewInterval is how often "run" checks whether the enrollment certificate is due for renewal
+const renewInterval = 12 * time.Hour
+
+// renewCertificate renews the client certificate issued at enrollment when it expires within
+// server.tls.renew_before. A certificate from server.tls.cert_file is managed outside the agent.
+func renewCertificate(ctx context.Context, cfg *config.Config, client *http.Client) error {
+	if cfg.Server.TLS.RenewBefore <= 0 || cfg.Server.TLS.CertFile != "" {
+		return nil
+	}
+	id, err := cfg.Identity()
+	if err != nil || id == nil {
+		return err
+	}
+	expiry, err := id.CertificateExpiry()
+	if err != nil || expiry.IsZero() || time.Until(expiry) > cfg.Server.TLS.RenewBefore {
+		return err
+	}
+
+	if err := id.Renew(ctx, client); err != nil {
+		return err
+	}
+	if err := id.Save(cfg.Agent.IdentityFile); err != nil {
+		return err
+	}
+	renewed, _ := id.CertificateExpiry()
+	fmt.Printf("Renewed the client certificate expiring %s, the new one expires %s\n",
+		expiry.Format(time.RFC3339), renewed.Format(time.RFC3339))
+	return nil
+}
+
+// renewPeriodically renews the enrollment certificate when it is due until ctx is done
+func renewPeriodically(ctx context.Context, cfg *config.Config, client *http.Client) {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := renewCertificate(ctx, cfg, client); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dlpagent/internal/check"
+	"dlpagent/internal/config"
+	"dlpagent/internal/history"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// historyFlags are the result filters shared by "history" and "report"
+type historyFlags struct {
+	checks   string
+	statuses string
+	since    string
+	until    string
+	limit    int
+}
+
+func (f *historyFlags) register(fs *flag.FlagSet, limit int) {
+	fs.StringVar(&f.checks, "check", "", "Only show these categories (comma-separated: antivirus, edr, dlp, ransomware)")
+	fs.StringVar(&f.statuses, "status", "", "Only show these statuses (comma-separated: effective, failed, error, timeout, skipped)")
+	fs.StringVar(&f.since, "since", "", "Only show results newer than a duration (e.g. 24h) or an RFC3339 time")
+	fs.StringVar(&f.until, "until", "", "Only show results older than a duration (e.g. 24h) or an RFC3339 time")
+	fs.IntVar(&f.limit, "limit", limit, "Maximum number of results, newest first (0 for all)")
+}
+
+// query builds the history query from the flags
+func (f *historyFlags) query() (history.Query, error) {
+	q := history.Query{Limit: f.limit}
+
+	for _, value := range splitList(f.checks) {
+		category := summary.Category(value)
+		if !containsCategory(category) {
+			return q, fmt.Errorf("unknown check %q (expected antivirus, edr, dlp or ransomware)", value)
+		}
+		q.Categories = append(q.Categories, category)
+	}
+
+	for _, value := range splitList(f.statuses) {
+		status := summary.Status(value)
+		switch status {
+		case summary.StatusEffective, summary.StatusFailed, summary.StatusError, summary.StatusTimeout, summary.StatusSkipped:
+		default:
+			return q, fmt.Errorf("unknown status %q (expected effective, failed, error, timeout or skipped)", value)
+		}
+		q.Statuses = append(q.Statuses, status)
+	}
+
+	if f.since != "" {
+		since, err := parseTime("-since", f.since)
+		if err != nil {
+			return q, err
+		}
+		q.Since = since
+	}
+	if f.until != "" {
+		until, err := parseTime("-until", f.until)
+		if err != nil {
+			return q, err
+		}
+		q.Until = until
+	}
+	return q, nil
+}
+
+// load reads the stored results of every check matching the flags from the result database
+func (f *historyFlags) load(cfg *config.Config) ([]history.Entry, history.Query, error) {
+	q, err := f.query()
+	if err != nil {
+		return nil, q, err
+	}
+	key, err := cfg.StorageKey()
+	if err != nil {
+		return nil, q, err
+	}
+	options := cfg.Store.Options()
+	options.Key = key
+	st := store.New(cfg.Store.Path, options)
+	importResultsFiles(check.Builtin(cfg, check.Env{Key: key}), st)
+	entries, err := st.Entries(q)
+	return entries, q, err
+}
+
+// importResultsFiles imports the results files of the checks into the result database the first
+// time it is used. Messages go to stderr to keep JSON output on stdout intact.
+func importResultsFiles(checks *check.Registry, st *store.Store) {
+	n, err := checks.ImportResultsFiles(st)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "Imported %d result(s) from the results files into %s\n", n, st.Path())
+	}
+}
+
+func historyCommand() *command {
+	var filters historyFlags
+	var format string
+
+	return &command{
+		name:    "history",
+		args:    "[decrypt FILE]",
+		summary: "Show stored check results, newest first, or decrypt a results or settings cache file",
+		aliases: resultAliases,
+		flags: func(fs *flag.FlagSet) {
+			filters.register(fs, 20)
+			fs.StringVar(&format, "format", "table", "Output format: table or json")
+		},
+		run: func(cfg *config.Config, args []string) int {
+			if len(args) > 0 && args[0] == "decrypt" {
+				return runDecrypt(cfg, args[1:])
+			}
+			if len(args) > 0 {
+				fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", args)
+				return 2
+			}
+			if format != "table" && format != "json" {
+				fmt.Fprintf(os.Stderr, "Error: unknown format %q, expected table or json\n", format)
+				return 2
+			}
+
+			entries, _, err := filters.load(cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 2
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(entries); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					return 3
+				}
+				return 0
+			}
+			printHistory(os.Stdout, entries)
+			return 0
+		},
+	}
+}
+
+// runDecrypt writes an encrypted results file, backup or settings cache to stdout in plain text
+func runDecrypt(cfg *config.Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: history decrypt expects one file")
+		return 2
+	}
+	path := args[0]
+	if filepath.Clean(path) == filepath.Clean(cfg.Store.Path) {
+		fmt.Fprintf(os.Stderr, "Error: %s is the result database; 'dlpagent history -format json' shows its results decrypted\n", path)
+		return 2
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 3
+	}
+	if !seal.IsSealed(data) {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not encrypted\n", path)
+	}
+	key, err := cfg.StorageKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	plain, err := key.Open(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		return 3
+	}
+	if _, err := os.Stdout.Write(plain); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 3
+	}
+	return 0
+}
+
+func reportCommand() *command {
+	var filters historyFlags
+	var format, output string
+
+	return &command{
+		name:    "report",
+		summary: "Export stored check results with totals as JSON, CSV or Markdown",
+		aliases: resultAliases,
+		flags: func(fs *flag.FlagSet) {
+			filters.register(fs, 0)
+			fs.StringVar(&format, "format", history.FormatMarkdown, "Report format: "+strings.Join(history.Formats, ", "))
+			fs.StringVar(&output, "output", "", "Write the report to this file instead of stdout")
+		},
+		run: func(cfg *config.Config, args []string) int {
+			if len(args) > 0 {
+				fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", args)
+				return 2
+			}
+
+			entries, q, err := filters.load(cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 2
+			}
+
+			w := io.Writer(os.Stdout)
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					return 3
+				}
+				defer file.Close()
+				w = file
+			}
+
+			if err := history.NewReport(entries, q.Since).Write(w, format); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 2
+			}
+			if output != "" {
+				fmt.Printf("✅ Report with %d results written to %s\n", len(entries), output)
+			}
+			return 0
+		},
+	}
+}
+
+func printHistory(w io.Writer, entries []history.Entry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No results")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCATEGORY\tNAME\tSTATUS\tDETAIL")
+	for _, e := range entries {
+		detail := e.Verdict
+		if detail == "" {
+			detail = e.Detail
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			e.Category, e.Name, e.Status, detail)
+	}
+	tw.Flush()
+}
+
+// parseTime accepts a duration before now or an RFC3339 time for the named flag
+func parseTime(flag, value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected a duration (e.g. 24h) or an RFC3339 time", flag, value)
+	}
+	return t, nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func containsCategory(category summary.Category) bool {
+	for _, c := range summary.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/ransomware"
+)
+
+// command is a dlpagent subcommand
+type command struct {
+	name    string
+	args    string // positional arguments shown in the usage line
+	summary string
+	aliases []config.Alias
+	flags   func(fs *flag.FlagSet) // registers flags specific to the command
+	run     func(cfg *config.Config, args []string) int
+}
+
+// Flags shared by the commands that run checks
+var checkAliases = []config.Alias{
+	{Flag: "file", Key: "dlp.files", Usage: "Path to test file for DLP (can be used multiple times)"},
+	{Flag: "antivirus-json", Key: "antivirus.json"},
+	{Flag: "dlp-json", Key: "dlp.json"},
+	{Flag: "dlp-url", Key: "dlp.url"},
+	{Flag: "method", Key: "dlp.method"},
+	{Flag: "skip-antivirus", Key: "antivirus.enabled", Usage: "Skip antivirus check", Negate: true},
+	{Flag: "skip-dlp", Key: "dlp.enabled", Usage: "Skip DLP check", Negate: true},
+	{Flag: "ransomware-json", Key: "ransomware.json"},
+	{Flag: "ransomware-dir", Key: "ransomware.dir"},
+	{Flag: "skip-ransomware", Key: "ransomware.enabled", Usage: "Skip ransomware simulation check", Negate: true},
+	{Flag: "edr-json", Key: "edr.json"},
+	{Flag: "skip-edr", Key: "edr.enabled", Usage: "Skip EDR behaviour simulation check", Negate: true},
+	{Flag: "edr-technique", Key: "edr.techniques", Usage: "MITRE ATT&CK technique ID of an EDR simulation to run (can be used multiple times, default: all)"},
+	{Flag: "fail-on", Key: "run.fail_on", Usage: "Category whose failures set the exit code (can be used multiple times: all, antivirus, edr, dlp, ransomware)"},
+}
+
+// Flags shared by the commands that read stored results
+var resultAliases = []config.Alias{
+	{Flag: "db", Key: "store.path"},
+	{Flag: "antivirus-json", Key: "antivirus.json"},
+	{Flag: "edr-json", Key: "edr.json"},
+	{Flag: "dlp-json", Key: "dlp.json"},
+	{Flag: "ransomware-json", Key: "ransomware.json"},
+}
+
+var onceAlias = config.Alias{Flag: "once", Key: "run.once", Usage: "Run the checks once, print a summary and exit (same as check all)"}
+
+func commands() []*command {
+	return []*command{
+		{
+			name:    "run",
+			summary: "Run the enabled checks on their schedules until interrupted",
+			aliases: append(append([]config.Alias{}, checkAliases...), onceAlias),
+			run:     runService,
+		},
+		{
+			name:    "check",
+			args:    "[antivirus|edr|dlp|ransomware|all]...",
+			summary: "Run checks once, print a summary and exit with 0 (effective), 1 (control failed) or 3 (error)",
+			aliases: checkAliases,
+			run:     runCheck,
+		},
+		historyCommand(),
+		reportCommand(),
+		enrollCommand(),
+		{
+			name:    "selftest",
+			summary: "Verify configuration, server connectivity and local permissions without running checks",
+			aliases: checkAliases,
+			run:     runSelftest,
+		},
+		{
+			name:    "config",
+			args:    "print|validate",
+			summary: "Print the effective configuration with the source of every value, or validate it",
+			aliases: append(append([]config.Alias{}, checkAliases...), onceAlias),
+			run:     runConfig,
+		},
+	}
+}
+
+func main() {
+	// The ransomware simulation re-executes the agent as its worker process
+	ransomware.RunWorkerIfRequested()
+
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 0 {
+			if cmd := lookupCommand(args[0]); cmd != nil {
+				os.Exit(cmd.execute([]string{"-h"}))
+			}
+		}
+		usage(os.Stdout)
+		os.Exit(0)
+	}
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	os.Exit(cmd.execute(args))
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: dlpagent <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nEvery command accepts the configuration flags (e.g. -server.url) and -config <file>.")
+	fmt.Fprintln(w, "Run 'dlpagent help <command>' for the flags of a command.")
+}
+
+// execute parses the configuration and flags of the command and runs it, returning the exit code
+func (c *command) execute(args []string) int {
+	fs := flag.NewFlagSet("dlpagent "+c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: dlpagent %s [flags]", c.name)
+		if c.args != "" {
+			fmt.Fprintf(w, " %s", c.args)
+		}
+		fmt.Fprintf(w, "\n\n%s\n\nFlags:\n", c.summary)
+		fs.PrintDefaults()
+	}
+	if c.flags != nil {
+		c.flags(fs)
+	}
+
+	cfg, rest, err := config.Parse(fs, args, c.aliases...)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	return c.run(cfg, rest)
+}
+
+// runConfig implements "config print" and "config validate"
+func runConfig(cfg *config.Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: expected print or validate")
+		return 2
+	}
+
+	switch args[0] {
+	case "print":
+		cfg.Print(os.Stdout)
+	case "validate":
+		// Parsing has already validated the configuration
+		if cfg.File != "" {
+			fmt.Printf("✅ Configuration is valid (%s)\n", cfg.File)
+		} else {
+			fmt.Println("✅ Configuration is valid")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown config command %q, expected print or validate\n", args[0])
+		return 2
+	}
+	return 0
+}
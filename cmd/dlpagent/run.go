@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/scheduler"
+	"dlpagent/internal/settings"
+)
+
+// runService implements "run": every enabled check runs on its schedule until interrupted
+func runService(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", args)
+		return 2
+	}
+
+	if err := initServerClients(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if cfg.Run.Once {
+		return runChecks(cfg, nil)
+	}
+
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Setup signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	// Each check runs on its own schedule, which the server settings can change at runtime
+	options, err := cfg.Schedule.SchedulerOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	sched := scheduler.New(options)
+	for _, c := range registry.Checks() {
+		sched.Add(c.ID(), scheduler.Every(c.Config().Interval), c.Config().Enabled, func() {
+			runRegisteredCheck(ctx, cfg, c)
+			reportSchedule(ctx, sched)
+		})
+	}
+
+	applySettings(sched, nil)
+	watchSettings(ctx, cfg, func(s *settings.Settings) {
+		applySettings(sched, s)
+	})
+	go uploadQueue.Run(ctx)
+	go renewPeriodically(ctx, cfg, serverClient)
+	sched.Start(ctx)
+	reportSchedule(ctx, sched)
+
+	// Wait for interrupt signal
+	<-sigChan
+	log.Println("Received interrupt signal, shutting down gracefully...")
+
+	// Cancel context to stop all goroutines
+	cancel()
+
+	// Wait for all checks to finish
+	sched.Wait()
+	log.Println("Shutdown complete")
+	return 0
+}
+
+// applySettings reconfigures the schedule of every check from the local configuration and the server settings
+func applySettings(sched *scheduler.Scheduler, s *settings.Settings) {
+	for _, c := range registry.Checks() {
+		local := c.Config()
+		schedule, enabled := checkSchedule(s, c.ID(), local.Schedule, local.Interval, local.Enabled)
+		if err := sched.Update(c.ID(), schedule, enabled); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"dlpagent/internal/check"
+	"dlpagent/internal/config"
+	"dlpagent/internal/host"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/summary"
+)
+
+// runSelftest implements "selftest": it verifies that the checks could run without running them
+func runSelftest(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %v\n", args)
+		return 2
+	}
+
+	failed := 0
+	report := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("❌ %s: %v\n", name, err)
+			return
+		}
+		fmt.Printf("✅ %s\n", name)
+	}
+
+	// Parsing has already validated the configuration
+	if cfg.File != "" {
+		report("Configuration loaded from "+cfg.File, nil)
+	} else {
+		report("Configuration loaded", nil)
+	}
+
+	facts := host.Collect(context.Background())
+	report(fmt.Sprintf("Host %s: %s %s, kernel %s, %s, agent %s", facts.Hostname, facts.Distro, facts.Arch, facts.Kernel, facts.IP, agentBuild(facts)), nil)
+	if len(facts.SecurityProducts) == 0 {
+		fmt.Println("⚠️  No known security product running")
+	}
+	for _, product := range facts.SecurityProducts {
+		report(fmt.Sprintf("Security product running: %s (%s)", product.Name, product.Process), nil)
+	}
+
+	switch id, err := cfg.Identity(); {
+	case err != nil:
+		report("Agent identity", err)
+	case id != nil:
+		report(fmt.Sprintf("Agent identity %s (%s authentication)", id.AgentID, id.Auth), nil)
+	case cfg.Agent.EnrollToken != "":
+		fmt.Println("⚠️  Agent not enrolled yet; it enrolls with agent.enroll_token when it starts")
+	default:
+		fmt.Println("⚠️  Agent not enrolled; requests to the server are anonymous (see dlpagent enroll)")
+	}
+
+	if cfg.Encryption.KeySource != seal.SourceNone {
+		_, err := cfg.StorageKey()
+		report("Encryption key from "+cfg.Encryption.KeySource, err)
+		if err != nil {
+			fmt.Println("\n❌ Selftest failed")
+			return summary.ExitError
+		}
+	}
+
+	if err := initServerClients(cfg); err != nil {
+		report("Server TLS configuration", err)
+		fmt.Println("\n❌ Selftest failed")
+		return summary.ExitError
+	}
+	report("Server TLS configuration", nil)
+
+	_, err := settingsClient.Refresh(context.Background())
+	report("Settings from "+cfg.Server.URL, err)
+
+	report("Result database writable: "+cfg.Store.Path, check.FileWritable(cfg.Store.Path))
+
+	ctx := context.Background()
+	for _, c := range registry.Checks() {
+		if !c.Config().Enabled {
+			continue
+		}
+		// Results files are replaced through a temporary file next to them
+		err := check.FileWritable(c.Config().JSON)
+		if err == nil {
+			err = check.DirWritable(filepath.Dir(c.Config().JSON))
+		}
+		report("Results file writable: "+c.Config().JSON, err)
+		if v, ok := c.(check.Verifier); ok {
+			for _, step := range v.Verify(ctx) {
+				report(step.Name, step.Err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n❌ Selftest failed: %d problem(s)\n", failed)
+		return summary.ExitError
+	}
+	fmt.Println("\n✅ Selftest passed")
+	return summary.ExitEffective
+}
+
+// agentBuild returns the agent version with the short build commit, if known
+func agentBuild(facts *host.Facts) string {
+	if len(facts.AgentCommit) > 12 {
+		return facts.AgentVersion + " (" + facts.AgentCommit[:12] + ")"
+	}
+	if facts.AgentCommit != "" {
+		return facts.AgentVersion + " (" + facts.AgentCommit + ")"
+	}
+	return facts.AgentVersion
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"dlpagent/internal/check"
+	"dlpagent/internal/config"
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/scheduler"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/settings"
+	"dlpagent/internal/store"
+)
+
+var (
+	settingsClient *settings.Client
+	serverClient   *http.Client
+	uploader       *dashboard.Uploader
+	uploadQueue    *dashboard.Queue
+	registry       *check.Registry
+	resultStore    *store.Store
+	storageKey     *seal.Key
+)
+
+// initServerClients creates the settings client and dashboard uploader for the server
+// and the registry of checks that use them
+func initServerClients(cfg *config.Config) error {
+	// Without an identity the agent keeps testing and enrolls again when it next starts
+	if err := autoEnroll(cfg); err != nil {
+		fmt.Printf("Warning: Failed to enroll, requests to the server are anonymous: %v\n", err)
+	}
+	id, err := cfg.Identity()
+	if err != nil {
+		return err
+	}
+	httpClient, err := cfg.ServerHTTPClient()
+	if err != nil {
+		return err
+	}
+	serverClient = httpClient
+	if err := renewCertificate(context.Background(), cfg, httpClient); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	testClient, err := cfg.TestHTTPClient()
+	if err != nil {
+		return err
+	}
+	if storageKey, err = cfg.StorageKey(); err != nil {
+		return err
+	}
+
+	settingsClient = settings.NewClient(cfg.Server.URL, cfg.Settings.CacheFile, httpClient)
+	settingsClient.CacheKey = storageKey
+	if settingsClient.Verifier, err = cfg.Signing.Verifier(); err != nil {
+		return err
+	}
+	uploader = dashboard.NewUploader(cfg.Server.URL, httpClient)
+	uploader.BatchSize = cfg.Upload.BatchSize
+	uploader.Compress = cfg.Upload.Compress
+	if id != nil {
+		uploader.AgentID = id.AgentID
+	}
+
+	storeOptions := cfg.Store.Options()
+	storeOptions.Key = storageKey
+	resultStore = store.New(cfg.Store.Path, storeOptions)
+	env := check.Env{
+		Settings:         settingsClient,
+		ServerHTTPClient: httpClient,
+		HTTPClient:       testClient,
+		Store:            resultStore,
+		Key:              storageKey,
+	}
+	if cfg.Proxy.Compare {
+		if env.DirectHTTPClient, err = cfg.DirectHTTPClient(); err != nil {
+			return err
+		}
+	}
+	registry = check.Builtin(cfg, env)
+	importResultsFiles(registry, env.Store)
+
+	uploadQueue = dashboard.NewQueue(uploader, resultStore, cfg.Upload.QueueOptions())
+	for _, c := range registry.Checks() {
+		uploadQueue.Add(c.ID(), c.Endpoint())
+	}
+	return nil
+}
+
+// getSettings returns the agent settings, warning when the last known good copy is used
+func getSettings(ctx context.Context) (*settings.Settings, error) {
+	s, err := settingsClient.Get(ctx)
+	if s == nil {
+		return nil, err
+	}
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return s, nil
+}
+
+// watchSettings applies the server settings now and again whenever they change, so
+// schedules pushed by the server take effect without a restart. SIGHUP and, when
+// settings.push_listen is set, POST /reload make the agent fetch them at once.
+func watchSettings(ctx context.Context, cfg *config.Config, apply func(*settings.Settings)) {
+	if s, err := getSettings(ctx); err != nil {
+		fmt.Printf("Warning: Failed to get settings: %v\n", err)
+	} else {
+		apply(s)
+	}
+
+	go settingsClient.Watch(ctx, cfg.Settings.PollInterval, apply)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				settingsClient.RequestReload()
+			}
+		}
+	}()
+
+	if cfg.Settings.PushListen != "" {
+		go func() {
+			if err := settingsClient.ListenForPush(ctx, cfg.Settings.PushListen); err != nil {
+				log.Printf("Warning: settings push listener stopped: %v", err)
+			}
+		}()
+	}
+}
+
+// checkSchedule resolves the schedule of a check from the local configuration and the server settings
+func checkSchedule(s *settings.Settings, name, cron string, interval time.Duration, enabled bool) (scheduler.Schedule, bool) {
+	interval, enabled = s.Schedule(name, interval, enabled)
+	schedule, err := scheduler.Parse(s.Cron(name, cron), interval)
+	if err != nil {
+		log.Printf("Warning: invalid %s schedule, using interval %s: %v", name, interval, err)
+		schedule = scheduler.Every(interval)
+	}
+	return schedule, enabled
+}
+
+// reportSchedule prints the next run of every check and the upload queue depth, and sends them
+// to the dashboard as the agent status
+func reportSchedule(ctx context.Context, sched *scheduler.Scheduler) {
+	statuses := sched.Statuses()
+	for _, status := range statuses {
+		if status.NextRun == nil {
+			fmt.Printf("Next %s check: disabled\n", status.Name)
+			continue
+		}
+		fmt.Printf("Next %s check: %s (%s)\n", status.Name, status.NextRun.Format(time.RFC3339), status.Schedule)
+	}
+
+	queue := uploadQueue.Status()
+	printQueueStatus(queue)
+
+	body := map[string]interface{}{"checks": statuses, "queue": queue}
+	if uploader.AgentID != "" {
+		body["agent_id"] = uploader.AgentID
+	}
+	if _, err := uploader.UploadJSON(ctx, dashboard.ScheduleEndpoint, body); err != nil {
+		fmt.Printf("Warning: Failed to send schedule to dashboard: %v\n", err)
+	}
+}
+
+// sendDashboardResult uploads the results of a check not yet accepted by the dashboard. Results
+// that cannot be sent stay queued in the result database.
+func sendDashboardResult(ctx context.Context, c check.Check) {
+	n, err := uploadQueue.Flush(ctx, c.ID())
+	if err != nil {
+		fmt.Printf("Warning: Failed to send results to dashboard, keeping them queued: %v\n", err)
+	}
+	if n > 0 {
+		fmt.Printf("Sent %d %s result(s) to the dashboard\n", n, c.ID())
+	}
+}
+
+// printQueueStatus prints the number of results waiting for the dashboard, if any
+func printQueueStatus(status dashboard.QueueStatus) {
+	if status.Pending == 0 {
+		return
+	}
+	fmt.Printf("Upload queue: %d result(s) waiting for the dashboard", status.Pending)
+	if status.NextRetry != nil {
+		fmt.Printf(", next retry at %s", status.NextRetry.Format(time.RFC3339))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,61 @@
+package antivirus
+
+import (
+	"regexp"
+	"strings"
+)
+
+// blockPageSignature identifies the block page of a gateway antivirus or web proxy
+type blockPageSignature struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var blockPageSignatures = []blockPageSignature{
+	{"FortiGuard", regexp.MustCompile(`(?i)fortiguard|fortigate.*(virus|blocked)`)},
+	{"Sophos", regexp.MustCompile(`(?i)sophos.*(blocked|threat|virus)`)},
+	{"Palo Alto Networks", regexp.MustCompile(`(?i)palo alto networks|virus/spyware download blocked`)},
+	{"Zscaler", regexp.MustCompile(`(?i)zscaler`)},
+	{"Symantec / Blue Coat", regexp.MustCompile(`(?i)blue ?coat|symantec.*(blocked|threat)`)},
+	{"McAfee / Skyhigh Web Gateway", regexp.MustCompile(`(?i)(mcafee|skyhigh) web gateway`)},
+	{"Cisco Umbrella / WSA", regexp.MustCompile(`(?i)cisco umbrella|ironport|this page has been blocked by cisco`)},
+	{"Check Point", regexp.MustCompile(`(?i)check ?point.*(blocked|threat prevention)`)},
+	// ClamAV and c-icap are also named by pages that are not blocks, such as documentation
+	{"Squid / ClamAV", regexp.MustCompile(`(?i)squidclamav|(c-icap|clamav).*((virus|threat) (found|detected)|blocked)|(virus|threat) (found|detected).*(c-icap|clamav)`)},
+	{"F5 BIG-IP ASM", regexp.MustCompile(`(?i)the requested url was rejected`)},
+	{"Generic virus block page", regexp.MustCompile(`(?i)(virus|malware|threat) (detected|found|blocked)|(download|file|request) (has been |was )?blocked`)},
+}
+
+// matchBlockPage returns the name of the block page signature the response matches.
+// Only HTML or error responses are checked, so a test file that happens to mention
+// a virus is not mistaken for a block page.
+func matchBlockPage(resp *CheckResponse) string {
+	if resp == nil || len(resp.Body) == 0 {
+		return ""
+	}
+
+	isHTML := strings.Contains(strings.ToLower(resp.ContentType), "text/html")
+	if !isHTML && resp.StatusCode < 400 {
+		return ""
+	}
+
+	body := resp.Body
+	if len(body) > 64*1024 {
+		body = body[:64*1024]
+	}
+
+	for _, sig := range blockPageSignatures {
+		if sig.Pattern.Match(body) {
+			return sig.Name
+		}
+	}
+
+	// A gateway that answers with a denial status is a block even without a known page, as long
+	// as the answer is a page or came through a proxy. A plain 403 from the test server itself is
+	// an error, not a block.
+	if (resp.StatusCode == 403 || resp.StatusCode == 451) && (isHTML || resp.Via != "") {
+		return "HTTP " + resp.StatusText
+	}
+
+	return ""
+}
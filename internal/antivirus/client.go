@@ -2,45 +2,60 @@ package antivirus
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"dlpagent/internal/signing"
 )
 
 type HTTPClient struct {
 	client *http.Client
+
+	// Verifier checks the signature of the catalog; nil accepts it unchecked
+	Verifier *signing.Verifier
 }
 
-func NewHTTPClient() *HTTPClient {
+// NewHTTPClient creates a client for test traffic. A nil httpClient uses a client with a one minute timeout.
+func NewHTTPClient(httpClient *http.Client) *HTTPClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Minute}
+	}
 	return &HTTPClient{
-		client: &http.Client{},
+		client: httpClient,
 	}
 }
 
-func (c *HTTPClient) SendRequest(req *CheckRequest) (*CheckResponse, error) {
+func (c *HTTPClient) SendRequest(ctx context.Context, req *CheckRequest) (*CheckResponse, error) {
 	// Get file_name that we're sending in the request
 	sentFileName := req.SentFileName
 
-	httpReq, err := c.buildRequest(req)
+	httpReq, err := c.buildRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
+	var written atomic.Bool
+	httpReq = withWroteRequest(httpReq, &written)
+
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, fmt.Errorf("failed to execute request: %w", resetAfterRequest(err, written.Load()))
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", resetAfterRequest(err, true))
 	}
 
 	// Try to parse JSON response for file_name
@@ -49,6 +64,9 @@ func (c *HTTPClient) SendRequest(req *CheckRequest) (*CheckResponse, error) {
 		StatusText: resp.Status,
 		FileName:   sentFileName, // Use sent file_name as default
 		Body:       bodyBytes,    // Store response body
+
+		ContentType: resp.Header.Get("Content-Type"),
+		Via:         resp.Header.Get("Via"),
 	}
 
 	// For GET requests, try to extract filename from Content-Disposition header
@@ -81,17 +99,37 @@ func (c *HTTPClient) SendRequest(req *CheckRequest) (*CheckResponse, error) {
 	return checkResp, nil
 }
 
-func (c *HTTPClient) buildRequest(req *CheckRequest) (*http.Request, error) {
+// withWroteRequest returns req with a trace that sets written once the whole request was sent.
+// Only a reset after that point can be a gateway stopping the test file.
+func withWroteRequest(req *http.Request, written *atomic.Bool) *http.Request {
+	trace := &httptrace.ClientTrace{WroteRequest: func(info httptrace.WroteRequestInfo) {
+		written.Store(info.Err == nil)
+	}}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+func (c *HTTPClient) buildRequest(ctx context.Context, req *CheckRequest) (*http.Request, error) {
 	var httpReq *http.Request
 	var err error
 
-	if req.HTTPMethod == "POST" || req.HTTPMethod == "PUT" {
+	if (req.HTTPMethod == "POST" || req.HTTPMethod == "PUT") && req.JSONBody != "" {
+		httpReq, err = http.NewRequestWithContext(ctx, req.HTTPMethod, req.TestURL, strings.NewReader(req.JSONBody))
+		if err != nil {
+			return nil, err
+		}
+
+		httpReq.Header.Set("Content-Type", "application/json")
+	} else if req.HTTPMethod == "POST" || req.HTTPMethod == "PUT" {
 		// Create multipart form-data with "file" and "file_name" fields
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
 		// Add file field
-		fileField, err := writer.CreateFormFile("file", "test.txt")
+		formFileName := "test.txt"
+		if req.SentFileName != "" {
+			formFileName = req.SentFileName
+		}
+		fileField, err := writer.CreateFormFile("file", formFileName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create form file: %w", err)
 		}
@@ -119,14 +157,14 @@ func (c *HTTPClient) buildRequest(req *CheckRequest) (*http.Request, error) {
 			return nil, fmt.Errorf("failed to close multipart writer: %w", err)
 		}
 
-		httpReq, err = http.NewRequest(req.HTTPMethod, req.TestURL, body)
+		httpReq, err = http.NewRequestWithContext(ctx, req.HTTPMethod, req.TestURL, body)
 		if err != nil {
 			return nil, err
 		}
 
 		httpReq.Header.Set("Content-Type", writer.FormDataContentType())
 	} else {
-		httpReq, err = http.NewRequest(req.HTTPMethod, req.TestURL, nil)
+		httpReq, err = http.NewRequestWithContext(ctx, req.HTTPMethod, req.TestURL, nil)
 	}
 
 	if err != nil {
@@ -137,8 +175,8 @@ func (c *HTTPClient) buildRequest(req *CheckRequest) (*http.Request, error) {
 }
 
 // GetAntivirusAPIInfo sends a GET request to the antivirus API endpoint to get scan configuration
-func (c *HTTPClient) GetAntivirusAPIInfo(apiURL string) (*AntivirusAPIResponse, error) {
-	httpReq, err := http.NewRequest("GET", apiURL, nil)
+func (c *HTTPClient) GetAntivirusAPIInfo(ctx context.Context, apiURL string) (*AntivirusAPIResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -161,3 +199,63 @@ func (c *HTTPClient) GetAntivirusAPIInfo(apiURL string) (*AntivirusAPIResponse,
 
 	return &apiResp, nil
 }
+
+// CatalogPath is the antivirus test-case catalog endpoint relative to the server URL
+const CatalogPath = "/api/antivirus"
+
+// GetAntivirusCatalog fetches the list of antivirus test cases from the API endpoint.
+// The server may return either a single test case or a list of them in the data field.
+func (c *HTTPClient) GetAntivirusCatalog(ctx context.Context, apiURL string) ([]AntivirusAPIData, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	bodyBytes, err = c.Verifier.Open(signing.KindAntivirusCatalog, bodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("catalog rejected: %w", err)
+	}
+
+	var envelope struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(bodyBytes, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	data := bytes.TrimSpace(envelope.Data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	// Legacy servers return a single test case object
+	if data[0] == '{' {
+		var single AntivirusAPIData
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("failed to parse test case: %w", err)
+		}
+		return []AntivirusAPIData{single}, nil
+	}
+
+	var catalog AntivirusCatalogResponse
+	if err := json.Unmarshal(bodyBytes, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+
+	return catalog.Data, nil
+}
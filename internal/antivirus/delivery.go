@@ -0,0 +1,273 @@
+package antivirus
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// isDeliveryProtocol reports whether mode is one of the delivery protocols handled by Deliver
+func isDeliveryProtocol(mode string) bool {
+	switch mode {
+	case DeliveryHTTPS, DeliveryGzip, DeliveryDeflate, DeliveryBrotli,
+		DeliveryMultipart, DeliveryChunked, DeliveryFTP, DeliveryIMAP, DeliveryPOP3:
+		return true
+	}
+	return false
+}
+
+// Deliver fetches the test file of a test case over the given delivery protocol.
+// An error means the delivery was stopped before the file reached the endpoint.
+func (c *HTTPClient) Deliver(ctx context.Context, tc AntivirusAPIData, mode, method string) (*CheckResponse, error) {
+	switch mode {
+	case DeliveryHTTPS:
+		return c.deliverHTTP(ctx, tc, mode, method, "")
+	case DeliveryGzip:
+		return c.deliverHTTP(ctx, tc, mode, method, "gzip")
+	case DeliveryDeflate:
+		return c.deliverHTTP(ctx, tc, mode, method, "deflate")
+	case DeliveryBrotli:
+		return c.deliverHTTP(ctx, tc, mode, method, "br")
+	case DeliveryMultipart, DeliveryChunked:
+		return c.deliverHTTP(ctx, tc, mode, method, "")
+	case DeliveryFTP:
+		return deliverFTP(ctx, tc)
+	case DeliveryIMAP, DeliveryPOP3:
+		return deliverMail(ctx, tc, mode)
+	}
+	return nil, fmt.Errorf("unsupported delivery protocol: %s", mode)
+}
+
+// dialContext connects to addr and bounds the connection by timeout and the deadline of ctx.
+// The connection is closed when ctx is cancelled, so a stalled server cannot block shutdown.
+func dialContext(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return &ctxConn{Conn: conn, stop: stop}, nil
+}
+
+// ctxConn is a connection that stops watching its context when closed
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// deliveryURL adds the delivery mode as a query parameter so the server can serve the
+// file accordingly, and switches to https for the HTTPS delivery
+func deliveryURL(rawURL, mode string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid test URL: %w", err)
+	}
+	if mode == DeliveryHTTPS && u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%s delivery requires an http(s) URL, got %q", mode, rawURL)
+	}
+
+	q := u.Query()
+	q.Set("delivery", mode)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// deliverHTTP downloads the test file over HTTP(S), optionally asking for a Content-Encoding.
+// The encoding is decoded here so the transport does not hide what the server sent.
+func (c *HTTPClient) deliverHTTP(ctx context.Context, tc AntivirusAPIData, mode, method, encoding string) (*CheckResponse, error) {
+	targetURL, err := deliveryURL(tc.URL, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if encoding != "" {
+		httpReq.Header.Set("Accept-Encoding", encoding)
+	} else {
+		httpReq.Header.Set("Accept-Encoding", "identity")
+	}
+	if mode == DeliveryMultipart {
+		httpReq.Header.Set("Accept", "multipart/mixed")
+	}
+
+	var written atomic.Bool
+	httpReq = withWroteRequest(httpReq, &written)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", resetAfterRequest(err, written.Load()))
+	}
+	defer resp.Body.Close()
+
+	var notes []string
+
+	body, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response body: %w", mode, resetAfterRequest(err, true))
+	}
+	if encoding != "" && !strings.EqualFold(resp.Header.Get("Content-Encoding"), encoding) {
+		notes = append(notes, fmt.Sprintf("server did not apply %s encoding", encoding))
+	}
+
+	if mode == DeliveryChunked && !isChunked(resp) {
+		notes = append(notes, "response was not chunked")
+	}
+
+	fileName := tc.File
+	if name := fileNameFromDisposition(resp.Header.Get("Content-Disposition")); name != "" {
+		fileName = name
+	}
+
+	if mode == DeliveryMultipart {
+		partName, partBody, err := firstMultipartFile(resp.Header.Get("Content-Type"), body)
+		if err != nil {
+			notes = append(notes, err.Error())
+		} else {
+			body = partBody
+			if partName != "" {
+				fileName = partName
+			}
+		}
+	}
+
+	statusText := resp.Status
+	if len(notes) > 0 {
+		statusText += " (" + strings.Join(notes, "; ") + ")"
+	}
+
+	return &CheckResponse{
+		StatusCode: resp.StatusCode,
+		StatusText: statusText,
+		FileName:   fileName,
+		Body:       body,
+
+		ContentType: resp.Header.Get("Content-Type"),
+		Via:         resp.Header.Get("Via"),
+	}, nil
+}
+
+// decodeBody reads the body and undoes the given Content-Encoding
+func decodeBody(r io.Reader, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.ReadAll(r)
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case "deflate":
+		// Servers send either zlib-wrapped or raw deflate data
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if body, err := inflateZlib(raw); err == nil {
+			return body, nil
+		}
+		return io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	case "br":
+		return io.ReadAll(brotli.NewReader(r))
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+}
+
+func isChunked(resp *http.Response) bool {
+	for _, te := range resp.TransferEncoding {
+		if te == "chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+var dispositionFileName = regexp.MustCompile(`filename="?([^";]+)"?`)
+
+func fileNameFromDisposition(contentDisposition string) string {
+	matches := dispositionFileName.FindStringSubmatch(contentDisposition)
+	if len(matches) > 1 {
+		return strings.TrimSpace(matches[1])
+	}
+	return ""
+}
+
+// firstMultipartFile returns the first part of a multipart response, preferring a part with a file name
+func firstMultipartFile(contentType string, body []byte) (string, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "", nil, fmt.Errorf("response was not multipart")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	var firstBody []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read multipart response: %w", err)
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read multipart part: %w", err)
+		}
+		if part.FileName() != "" {
+			return part.FileName(), data, nil
+		}
+		if firstBody == nil {
+			firstBody = data
+		}
+	}
+
+	if firstBody == nil {
+		return "", nil, fmt.Errorf("multipart response has no parts")
+	}
+	return "", firstBody, nil
+}
+
+func inflateZlib(raw []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
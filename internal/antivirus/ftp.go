@@ -0,0 +1,163 @@
+package antivirus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ftpTimeout = 30 * time.Second
+
+// deliverFTP downloads the test file from an ftp:// URL using passive mode
+func deliverFTP(ctx context.Context, tc AntivirusAPIData) (*CheckResponse, error) {
+	u, err := url.Parse(tc.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid test URL: %w", err)
+	}
+	if u.Scheme != "ftp" {
+		return nil, fmt.Errorf("ftp delivery requires an ftp:// URL, got %q", tc.URL)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+
+	user, password := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+	}
+
+	conn, err := dialContext(ctx, host, ftpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to FTP server: %w", err)
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return nil, fmt.Errorf("FTP greeting failed: %w", err)
+	}
+
+	code, _, err := ftpCommand(text, "USER "+user)
+	if err != nil {
+		return nil, err
+	}
+	if code == 331 {
+		if code, _, err = ftpCommand(text, "PASS "+password); err != nil {
+			return nil, err
+		}
+	}
+	if code != 230 {
+		return nil, fmt.Errorf("FTP login failed with code %d", code)
+	}
+
+	if _, _, err := ftpExpect(text, 200, "TYPE I"); err != nil {
+		return nil, err
+	}
+
+	_, msg, err := ftpExpect(text, 227, "PASV")
+	if err != nil {
+		return nil, err
+	}
+	dataAddr, err := parsePASV(msg, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	dataConn, err := dialContext(ctx, dataAddr, ftpTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FTP data connection: %w", err)
+	}
+	defer dataConn.Close()
+
+	if code, msg, err = ftpCommand(text, "RETR "+u.Path); err != nil {
+		return nil, err
+	}
+	if code != 125 && code != 150 {
+		return nil, fmt.Errorf("FTP RETR failed: %d %s", code, msg)
+	}
+
+	// RETR was accepted, so a reset of the data connection is the transfer being stopped
+	body, err := io.ReadAll(dataConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FTP data: %w", resetAfterRequest(err, true))
+	}
+	dataConn.Close()
+
+	code, msg, err = text.ReadResponse(226)
+	if err != nil {
+		return nil, fmt.Errorf("FTP transfer failed: %w", err)
+	}
+	ftpCommand(text, "QUIT")
+
+	fileName := tc.File
+	if fileName == "" {
+		fileName = path.Base(u.Path)
+	}
+
+	return &CheckResponse{
+		StatusCode: code,
+		StatusText: fmt.Sprintf("%d %s", code, msg),
+		FileName:   fileName,
+		Body:       body,
+	}, nil
+}
+
+// ftpCommand sends a command and returns the reply code and message without checking it
+func ftpCommand(text *textproto.Conn, cmd string) (int, string, error) {
+	if err := text.PrintfLine("%s", cmd); err != nil {
+		return 0, "", fmt.Errorf("failed to send FTP command: %w", err)
+	}
+	code, msg, err := text.ReadResponse(0)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read FTP reply: %w", err)
+	}
+	return code, msg, nil
+}
+
+// ftpExpect sends a command and fails unless the reply has the expected code
+func ftpExpect(text *textproto.Conn, expect int, cmd string) (int, string, error) {
+	code, msg, err := ftpCommand(text, cmd)
+	if err != nil {
+		return 0, "", err
+	}
+	if code != expect {
+		verb, _, _ := strings.Cut(cmd, " ")
+		return code, msg, fmt.Errorf("FTP %s failed: %d %s", verb, code, msg)
+	}
+	return code, msg, nil
+}
+
+// parsePASV parses "Entering Passive Mode (h1,h2,h3,h4,p1,p2)". The advertised host is
+// ignored in favour of the control connection host, as NATed servers often report a private address.
+func parsePASV(msg, controlHost string) (string, error) {
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+	if start < 0 || end <= start {
+		return "", fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("invalid PASV port: %s", msg)
+	}
+
+	return net.JoinHostPort(controlHost, strconv.Itoa(p1*256+p2)), nil
+}
@@ -0,0 +1,389 @@
+package antivirus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mailTimeout = 30 * time.Second
+
+// deliverMail pulls the test file as an email attachment over IMAP or POP3.
+// When the test case URL is not an imap:// or pop3:// URL, a local stand-in
+// mail server serving the test file content is started on the loopback interface.
+// No network control sees that delivery, so the response is marked as Loopback.
+func deliverMail(ctx context.Context, tc AntivirusAPIData, mode string) (*CheckResponse, error) {
+	u, err := url.Parse(tc.URL)
+	standIn := err != nil || (u.Scheme != DeliveryIMAP && u.Scheme != DeliveryPOP3)
+	if standIn {
+		if tc.FileContent == "" {
+			return nil, fmt.Errorf("%s delivery needs file_content for the local stand-in server", mode)
+		}
+
+		addr, stop, err := startMailStandIn(mode, buildMailMessage(tc))
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+
+		u = &url.URL{Scheme: mode, Host: addr, User: url.UserPassword("agent", "agent")}
+	} else if u.Scheme != mode {
+		return nil, fmt.Errorf("%s delivery requires a %s:// URL, got %q", mode, mode, tc.URL)
+	}
+
+	var raw []byte
+	if mode == DeliveryPOP3 {
+		raw, err = fetchPOP3(ctx, u)
+	} else {
+		raw, err = fetchIMAP(ctx, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fileName, body, err := extractAttachment(raw)
+	if err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		fileName = tc.File
+	}
+
+	return &CheckResponse{
+		StatusCode: 0,
+		StatusText: strings.ToUpper(mode) + " message retrieved",
+		FileName:   fileName,
+		Body:       body,
+		Loopback:   standIn,
+	}, nil
+}
+
+// buildMailMessage builds a MIME message with the test file as a base64 attachment
+func buildMailMessage(tc AntivirusAPIData) []byte {
+	fileName := tc.File
+	if fileName == "" {
+		fileName = time.Now().Format("2006_01_02_15_04_05") + ".txt"
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: agent@localhost\r\n")
+	fmt.Fprintf(&buf, "To: agent@localhost\r\n")
+	fmt.Fprintf(&buf, "Subject: Antivirus delivery test\r\n")
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	textPart, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	io.WriteString(textPart, "Antivirus delivery test attachment.\r\n")
+
+	filePart, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/octet-stream"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": fileName})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	encoded := base64.StdEncoding.EncodeToString([]byte(tc.FileContent))
+	for len(encoded) > 76 {
+		io.WriteString(filePart, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(filePart, encoded+"\r\n")
+
+	writer.Close()
+	return buf.Bytes()
+}
+
+// extractAttachment returns the first attachment of a MIME message
+func extractAttachment(raw []byte) (string, []byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "", nil, fmt.Errorf("message has no attachment")
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", nil, fmt.Errorf("message has no attachment")
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read message part: %w", err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		var r io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			r = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode attachment: %w", err)
+		}
+		return part.FileName(), body, nil
+	}
+}
+
+func mailCredentials(u *url.URL) (string, string) {
+	if u.User == nil {
+		return "anonymous", ""
+	}
+	password, _ := u.User.Password()
+	return u.User.Username(), password
+}
+
+func dialMail(ctx context.Context, u *url.URL, defaultPort string) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	conn, err := dialContext(ctx, host, mailTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mail server: %w", err)
+	}
+	return conn, nil
+}
+
+// fetchPOP3 retrieves the first message of the mailbox
+func fetchPOP3(ctx context.Context, u *url.URL) ([]byte, error) {
+	conn, err := dialMail(ctx, u, "110")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	pop3 := func(cmd string) error {
+		if cmd != "" {
+			if err := text.PrintfLine("%s", cmd); err != nil {
+				return err
+			}
+		}
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			verb, _, _ := strings.Cut(cmd, " ")
+			return fmt.Errorf("POP3 %s failed: %s", verb, line)
+		}
+		return nil
+	}
+
+	user, password := mailCredentials(u)
+	if err := pop3(""); err != nil {
+		return nil, fmt.Errorf("POP3 greeting failed: %w", err)
+	}
+	if err := pop3("USER " + user); err != nil {
+		return nil, err
+	}
+	if err := pop3("PASS " + password); err != nil {
+		return nil, err
+	}
+	if err := pop3("RETR 1"); err != nil {
+		return nil, err
+	}
+
+	raw, err := text.ReadDotBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POP3 message: %w", err)
+	}
+	pop3("QUIT")
+
+	return raw, nil
+}
+
+// fetchIMAP retrieves the first message of the mailbox named in the URL path (default INBOX)
+func fetchIMAP(ctx context.Context, u *url.URL) ([]byte, error) {
+	conn, err := dialMail(ctx, u, "143")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	if _, err := reader.ReadString('\n'); err != nil {
+		return nil, fmt.Errorf("IMAP greeting failed: %w", err)
+	}
+
+	mailbox := strings.TrimPrefix(u.Path, "/")
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	user, password := mailCredentials(u)
+
+	tag := 0
+	imap := func(cmd string) ([]byte, error) {
+		tag++
+		prefix := fmt.Sprintf("a%d", tag)
+		if _, err := fmt.Fprintf(conn, "%s %s\r\n", prefix, cmd); err != nil {
+			return nil, err
+		}
+
+		var literal []byte
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			if strings.HasPrefix(line, prefix+" ") {
+				status := strings.TrimPrefix(line, prefix+" ")
+				if !strings.HasPrefix(status, "OK") {
+					verb, _, _ := strings.Cut(cmd, " ")
+					return nil, fmt.Errorf("IMAP %s failed: %s", verb, status)
+				}
+				return literal, nil
+			}
+
+			// Untagged response carrying a literal: "* 1 FETCH (BODY[] {123}"
+			if open := strings.LastIndex(line, "{"); open >= 0 && strings.HasSuffix(line, "}") {
+				size, err := strconv.Atoi(line[open+1 : len(line)-1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid IMAP literal: %s", line)
+				}
+				literal = make([]byte, size)
+				if _, err := io.ReadFull(reader, literal); err != nil {
+					return nil, fmt.Errorf("failed to read IMAP literal: %w", err)
+				}
+			}
+		}
+	}
+
+	if _, err := imap(fmt.Sprintf("LOGIN %s %s", strconv.Quote(user), strconv.Quote(password))); err != nil {
+		return nil, err
+	}
+	if _, err := imap("SELECT " + strconv.Quote(mailbox)); err != nil {
+		return nil, err
+	}
+	raw, err := imap("FETCH 1 BODY.PEEK[]")
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("IMAP FETCH returned no message")
+	}
+	imap("LOGOUT")
+
+	return raw, nil
+}
+
+// startMailStandIn serves a single message over POP3 or IMAP on a loopback port
+func startMailStandIn(mode string, message []byte) (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start local %s server: %w", mode, err)
+	}
+
+	serve := servePOP3
+	if mode == DeliveryIMAP {
+		serve = serveIMAP
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(mailTimeout))
+				serve(conn, message)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), func() { listener.Close() }, nil
+}
+
+func servePOP3(conn net.Conn, message []byte) {
+	text := textproto.NewConn(conn)
+	text.PrintfLine("+OK POP3 stand-in ready")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "USER", "PASS", "NOOP":
+			text.PrintfLine("+OK")
+		case "STAT":
+			text.PrintfLine("+OK 1 %d", len(message))
+		case "LIST":
+			text.PrintfLine("+OK 1 messages")
+			text.PrintfLine("1 %d", len(message))
+			text.PrintfLine(".")
+		case "RETR":
+			text.PrintfLine("+OK %d octets", len(message))
+			w := text.DotWriter()
+			w.Write(message)
+			w.Close()
+		case "QUIT":
+			text.PrintfLine("+OK bye")
+			return
+		default:
+			text.PrintfLine("-ERR unsupported command")
+		}
+	}
+}
+
+func serveIMAP(conn net.Conn, message []byte) {
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "* OK IMAP4rev1 stand-in ready\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		tag, verb := fields[0], strings.ToUpper(fields[1])
+
+		switch verb {
+		case "CAPABILITY":
+			fmt.Fprintf(conn, "* CAPABILITY IMAP4rev1\r\n%s OK CAPABILITY completed\r\n", tag)
+		case "LOGIN", "NOOP":
+			fmt.Fprintf(conn, "%s OK %s completed\r\n", tag, verb)
+		case "SELECT", "EXAMINE":
+			fmt.Fprintf(conn, "* 1 EXISTS\r\n* 0 RECENT\r\n%s OK [READ-WRITE] %s completed\r\n", tag, verb)
+		case "FETCH":
+			fmt.Fprintf(conn, "* 1 FETCH (BODY[] {%d}\r\n", len(message))
+			conn.Write(message)
+			fmt.Fprintf(conn, ")\r\n%s OK FETCH completed\r\n", tag)
+		case "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			return
+		default:
+			fmt.Fprintf(conn, "%s BAD unsupported command\r\n", tag)
+		}
+	}
+}
@@ -7,13 +7,17 @@ type CheckRequest struct {
 	TestURL      string
 	HTTPMethod   string
 	SentFileName string // file_name that we're sending in the request
+	JSONBody     string // raw JSON body sent instead of multipart form-data
 }
 
 type CheckResponse struct {
-	StatusCode int
-	StatusText string
-	FileName   string // file_name from server response
-	Body       []byte // response body for GET requests (file content)
+	StatusCode  int
+	StatusText  string
+	FileName    string // file_name from server response
+	Body        []byte // response body for GET requests (file content)
+	ContentType string // Content-Type of the response, used to recognise block pages
+	Via         string // Via header, set when a proxy or gateway relayed the response
+	Loopback    bool   // served by a local stand-in server, so the file never crossed the network
 }
 
 type Result struct {
@@ -24,18 +28,50 @@ type Result struct {
 	FilePath        string // path where file was checked
 	IP              string // IP address of the computer sending the request
 	FileContent     string // content of the file
+	TestID          string // catalog test case identifier
+	Method          string // HTTP method used for the test case
+	URL             string // URL used for the test case
+	Delivery        string // delivery mode (download, upload)
+	Expected        string // expected outcome (blocked, allowed)
+	Passed          bool   // whether the actual outcome matched the expected one
+	NetworkBlocked  bool   // the delivery was stopped on the network (gateway antivirus)
+	EndpointRemoved bool   // the delivered file was removed on the endpoint (endpoint antivirus)
+	Verdict         Verdict
+	BlockSignature  string // block page signature or reset that identified a network block
+	Route           string // "proxy" or "direct" when both paths are compared
+	EndpointOnly    bool   // delivered over loopback, so only the endpoint layer was tested
 }
 
 // CheckResultEntry represents a single result entry stored in JSON
 type CheckResultEntry struct {
-	Timestamp       time.Time `json:"timestamp"`
-	FileName        string    `json:"file_name"`
-	StatusText      string    `json:"status_text"`
-	IsVirusDetected bool      `json:"is_virus_detected"`
-	FileExists      bool      `json:"file_exists"`
-	FilePath        string    `json:"file_path"`
-	IP              string    `json:"ip"`
-	FileContent     string    `json:"file_content"`
+	Timestamp       time.Time       `json:"timestamp"`
+	FileName        string          `json:"file_name"`
+	StatusText      string          `json:"status_text"`
+	IsVirusDetected bool            `json:"is_virus_detected"`
+	FileExists      bool            `json:"file_exists"`
+	FilePath        string          `json:"file_path"`
+	IP              string          `json:"ip"`
+	FileContent     string          `json:"file_content"`
+	TestID          string          `json:"test_id,omitempty"`
+	Method          string          `json:"method,omitempty"`
+	URL             string          `json:"url,omitempty"`
+	Delivery        string          `json:"delivery,omitempty"`
+	Expected        string          `json:"expected,omitempty"`
+	Passed          bool            `json:"passed"`
+	NetworkBlocked  bool            `json:"network_blocked"`
+	EndpointRemoved bool            `json:"endpoint_removed"`
+	Verdict         Verdict         `json:"verdict"`
+	BlockSignature  string          `json:"block_signature,omitempty"`
+	Route           string          `json:"route,omitempty"`
+	EndpointOnly    bool            `json:"endpoint_only,omitempty"`
+	Controls        []ControlResult `json:"controls"`
+}
+
+// ControlResult is the status of a single antivirus layer, reported to the dashboard as its own control
+type ControlResult struct {
+	Control string `json:"control"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 // CheckResultsHistory stores the history of check results
@@ -49,11 +85,47 @@ type AntivirusAPIResponse struct {
 	Data    AntivirusAPIData `json:"data"`
 }
 
-// AntivirusAPIData represents the data field in the API response
+// AntivirusAPIData represents the data field in the API response.
+// Each entry is one test case of the antivirus catalog.
 type AntivirusAPIData struct {
-	File        string `json:"file"`
-	FileContent string `json:"file_content"`
-	URL         string `json:"url"`
-	Method      string `json:"method"`
-	JSON        string `json:"json"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	FileContent string   `json:"file_content"`
+	URL         string   `json:"url"`
+	Method      string   `json:"method"`
+	JSON        string   `json:"json"`
+	Expected    string   `json:"expected"`   // blocked or allowed
+	Delivery    string   `json:"delivery"`   // download, upload or one of the delivery protocols
+	Deliveries  []string `json:"deliveries"` // run the test case once per listed delivery
+}
+
+// Expected outcomes of a catalog test case
+const (
+	ExpectBlocked = "blocked"
+	ExpectAllowed = "allowed"
+)
+
+// Delivery modes of a catalog test case
+const (
+	DeliveryDownload = "download"
+	DeliveryUpload   = "upload"
+
+	// Delivery protocols, each fetches the test file and checks it on the endpoint
+	DeliveryHTTPS     = "https"
+	DeliveryGzip      = "http-gzip"
+	DeliveryDeflate   = "http-deflate"
+	DeliveryBrotli    = "http-br"
+	DeliveryMultipart = "multipart"
+	DeliveryChunked   = "chunked"
+	DeliveryFTP       = "ftp"
+	DeliveryIMAP      = "imap"
+	DeliveryPOP3      = "pop3"
+)
+
+// AntivirusCatalogResponse represents the /api/antivirus response when the
+// server returns a list of test cases instead of a single one
+type AntivirusCatalogResponse struct {
+	Success bool               `json:"success"`
+	Data    []AntivirusAPIData `json:"data"`
 }
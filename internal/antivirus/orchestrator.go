@@ -1,13 +1,18 @@
 package antivirus
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"dlpagent/internal/host"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/seal"
 )
 
 type Orchestrator struct {
@@ -16,35 +21,181 @@ type Orchestrator struct {
 	mapMutex sync.RWMutex
 }
 
-func NewOrchestrator() *Orchestrator {
+// NewOrchestrator creates an orchestrator sending test traffic with httpClient (nil for a default client)
+func NewOrchestrator(httpClient *http.Client) *Orchestrator {
 	return &Orchestrator{
-		client:  NewHTTPClient(),
+		client:  NewHTTPClient(httpClient),
 		fileMap: make(map[string]bool),
 	}
 }
 
-// getLocalIP returns the local IP address of the machine
-func getLocalIP() string {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil {
-		return "127.0.0.1"
+func (o *Orchestrator) RunAntivirusCheck(ctx context.Context, settingUrl string) *Result {
+	// Send GET request to http://127.0.0.1:8000/api/antivirus/download?type=file
+	return o.runDownload(ctx, settingUrl, "GET")
+}
+
+// RunCatalog runs every test case of the antivirus catalog and returns one result
+// per case and delivery. Test cases listing several deliveries are expanded.
+// When ctx is done the remaining cases are not run and the interrupted one is dropped.
+func (o *Orchestrator) RunCatalog(ctx context.Context, cases []AntivirusAPIData) []*Result {
+	expanded := expand(cases)
+	results := make([]*Result, 0, len(expanded))
+	for _, tc := range expanded {
+		result := o.RunTestCase(ctx, tc)
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// expand returns one test case per delivery of test cases listing several
+func expand(cases []AntivirusAPIData) []AntivirusAPIData {
+	var expanded []AntivirusAPIData
+	for _, tc := range cases {
+		if len(tc.Deliveries) == 0 {
+			expanded = append(expanded, tc)
+			continue
+		}
+		for _, delivery := range tc.Deliveries {
+			single := tc
+			single.Delivery = delivery
+			single.Deliveries = nil
+			expanded = append(expanded, single)
+		}
 	}
-	defer conn.Close()
+	return expanded
+}
 
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String()
+// HTTPCases returns the test cases delivered over HTTP, one per delivery. FTP and mail
+// deliveries do not go through HTTP proxies.
+func HTTPCases(cases []AntivirusAPIData) []AntivirusAPIData {
+	var httpCases []AntivirusAPIData
+	for _, tc := range expand(cases) {
+		switch strings.ToLower(tc.Delivery) {
+		case "", DeliveryDownload, DeliveryUpload, DeliveryHTTPS, DeliveryGzip, DeliveryDeflate,
+			DeliveryBrotli, DeliveryMultipart, DeliveryChunked:
+			httpCases = append(httpCases, tc)
+		}
+	}
+	return httpCases
 }
 
-func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
-	// Send GET request to http://127.0.0.1:8000/api/antivirus/download?type=file
+// RunTestCase runs a single catalog test case and compares the outcome with the expected one
+func (o *Orchestrator) RunTestCase(ctx context.Context, tc AntivirusAPIData) *Result {
+	method := strings.ToUpper(tc.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	delivery := strings.ToLower(tc.Delivery)
+	if delivery == "" {
+		if method == "POST" || method == "PUT" {
+			delivery = DeliveryUpload
+		} else {
+			delivery = DeliveryDownload
+		}
+	}
+
+	expected := strings.ToLower(tc.Expected)
+	if expected == "" {
+		expected = ExpectBlocked
+	}
+
+	var result *Result
+	switch {
+	case delivery == DeliveryDownload:
+		result = o.runDownload(ctx, tc.URL, method)
+	case delivery == DeliveryUpload:
+		result = o.runUpload(ctx, tc, method)
+	case isDeliveryProtocol(delivery):
+		result = o.runDelivery(ctx, tc, delivery, method)
+	default:
+		result = &Result{
+			Verdict:    VerdictError,
+			StatusText: "Unsupported delivery mode: " + tc.Delivery,
+			IP:         host.PrimaryIP(),
+		}
+	}
+
+	result.TestID = tc.ID
+	if result.TestID == "" {
+		result.TestID = tc.Name
+	}
+	result.Method = method
+	result.URL = tc.URL
+	result.Delivery = delivery
+	result.Expected = expected
+	result.Passed = matchesExpectation(result.Verdict, expected)
+
+	return result
+}
+
+// matchesExpectation reports whether a verdict is the expected outcome. Errors never pass.
+func matchesExpectation(verdict Verdict, expected string) bool {
+	switch verdict {
+	case VerdictNetworkBlocked, VerdictEndpointRemoved:
+		return expected == ExpectBlocked
+	case VerdictPersisted, VerdictDelivered:
+		return expected == ExpectAllowed
+	}
+	return false
+}
+
+// runUpload sends the test file content to the test case URL
+func (o *Orchestrator) runUpload(ctx context.Context, tc AntivirusAPIData, method string) *Result {
+	req := &CheckRequest{
+		TestFile:     tc.FileContent,
+		TestURL:      tc.URL,
+		HTTPMethod:   method,
+		SentFileName: tc.File,
+		JSONBody:     tc.JSON,
+	}
+
+	resp, err := o.client.SendRequest(ctx, req)
+	result := EvaluateResult(resp, err)
+	result.IP = host.PrimaryIP()
+	result.FileName = req.SentFileName
+	result.FileContent = tc.FileContent
+
+	return result
+}
+
+// runDownload downloads the test file, saves it locally and checks whether it survives
+func (o *Orchestrator) runDownload(ctx context.Context, testURL, method string) *Result {
 	req := &CheckRequest{
 		TestFile:     "", // No file content for GET
-		TestURL:      settingUrl,
-		HTTPMethod:   "GET",
+		TestURL:      testURL,
+		HTTPMethod:   method,
 		SentFileName: "",
 	}
 
-	resp, err := o.client.SendRequest(req)
+	resp, err := o.client.SendRequest(ctx, req)
+	return o.persistDelivery(ctx, resp, err)
+}
+
+// runDelivery fetches the test file over the given delivery protocol and checks whether it survives
+func (o *Orchestrator) runDelivery(ctx context.Context, tc AntivirusAPIData, mode, method string) *Result {
+	resp, err := o.client.Deliver(ctx, tc, mode, method)
+	result := o.persistDelivery(ctx, resp, err)
+	result.EndpointOnly = resp != nil && resp.Loopback
+	return result
+}
+
+// uploadFileName returns the last element of a file name chosen by the test server, so that the
+// file is saved inside the uploads directory, or "" if nothing usable is left
+func uploadFileName(name string) string {
+	// Backslashes separate directories in names sent by Windows servers on every platform
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return ""
+	}
+	return name
+}
+
+// persistDelivery saves a delivered test file and checks whether the endpoint antivirus removes it
+func (o *Orchestrator) persistDelivery(ctx context.Context, resp *CheckResponse, err error) *Result {
 	result := EvaluateResult(resp, err)
 
 	fileExists := false
@@ -52,13 +203,13 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 	var fileContent string
 
 	// Get local IP address
-	result.IP = getLocalIP()
+	result.IP = host.PrimaryIP()
 	result.FileContent = "" // Initialize as empty, will be set if file is received
 
 	// If request succeeded, save the file locally
-	if !result.IsVirusDetected && resp != nil && len(resp.Body) > 0 {
+	if result.Verdict == VerdictDelivered && len(resp.Body) > 0 {
 		// Use file name from response or generate one with timestamp
-		fileName := resp.FileName
+		fileName := uploadFileName(resp.FileName)
 		if fileName == "" {
 			fileName = time.Now().Format("2006_01_02_15_04_05") + ".txt"
 		}
@@ -70,9 +221,9 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 		uploadsDir := "uploads"
 		if err := os.MkdirAll(uploadsDir, 0755); err != nil {
 			return &Result{
-				IsVirusDetected: true,
-				StatusText:      "Failed to create uploads directory: " + err.Error(),
-				IP:              getLocalIP(),
+				Verdict:    VerdictError,
+				StatusText: "Failed to create uploads directory: " + err.Error(),
+				IP:         host.PrimaryIP(),
 			}
 		}
 
@@ -80,9 +231,9 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 		savedFilePath = filepath.Join(uploadsDir, fileName)
 		if err := os.WriteFile(savedFilePath, resp.Body, 0644); err != nil {
 			return &Result{
-				IsVirusDetected: true,
-				StatusText:      "Failed to save file: " + err.Error(),
-				IP:              getLocalIP(),
+				Verdict:    VerdictError,
+				StatusText: "Failed to save file: " + err.Error(),
+				IP:         host.PrimaryIP(),
 			}
 		}
 
@@ -91,7 +242,15 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 		result.FileContent = fileContent
 
 		// Wait 5 seconds
-		time.Sleep(5 * time.Second)
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			return &Result{
+				Verdict:    VerdictError,
+				StatusText: "Interrupted while waiting for the endpoint antivirus: " + ctx.Err().Error(),
+				IP:         host.PrimaryIP(),
+			}
+		}
 
 		// Check if file still exists
 		if _, err := os.Stat(savedFilePath); err == nil {
@@ -103,8 +262,16 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 		}
 
 		result.FileExists = fileExists
-	} else if !result.IsVirusDetected && resp != nil {
+		result.EndpointRemoved = !fileExists
+		result.IsVirusDetected = !fileExists
+		if fileExists {
+			result.Verdict = VerdictPersisted
+		} else {
+			result.Verdict = VerdictEndpointRemoved
+		}
+	} else if result.Verdict == VerdictDelivered {
 		// Request succeeded but no file content
+		result.Verdict = VerdictError
 		result.FileExists = false
 		result.StatusText = fmt.Sprintf("Request succeeded: %s. No file content received", resp.StatusText)
 		result.FileContent = ""
@@ -113,22 +280,9 @@ func (o *Orchestrator) RunAntivirusCheck(settingUrl string) *Result {
 	return result
 }
 
-// SaveResultToJSON saves the result to JSON file, keeping only last 15 entries
-func (o *Orchestrator) SaveResultToJSON(result *Result, jsonFilePath string) error {
-	history := &CheckResultsHistory{
-		Results: []CheckResultEntry{},
-	}
-
-	// Read existing results if file exists
-	if _, err := os.Stat(jsonFilePath); err == nil {
-		data, err := os.ReadFile(jsonFilePath)
-		if err == nil {
-			json.Unmarshal(data, history)
-		}
-	}
-
-	// Create new entry
-	entry := CheckResultEntry{
+// NewCheckResultEntry returns the stored form of a result
+func NewCheckResultEntry(result *Result) CheckResultEntry {
+	return CheckResultEntry{
 		Timestamp:       time.Now(),
 		FileName:        result.FileName,
 		StatusText:      result.StatusText,
@@ -137,26 +291,35 @@ func (o *Orchestrator) SaveResultToJSON(result *Result, jsonFilePath string) err
 		FilePath:        result.FilePath,
 		IP:              result.IP,
 		FileContent:     result.FileContent,
+		TestID:          result.TestID,
+		Method:          result.Method,
+		URL:             result.URL,
+		Delivery:        result.Delivery,
+		Expected:        result.Expected,
+		Passed:          result.Passed,
+		NetworkBlocked:  result.NetworkBlocked,
+		EndpointRemoved: result.EndpointRemoved,
+		Verdict:         result.Verdict,
+		BlockSignature:  result.BlockSignature,
+		Route:           result.Route,
+		EndpointOnly:    result.EndpointOnly,
+		Controls:        Controls(result),
 	}
+}
 
-	// Add new entry
-	history.Results = append(history.Results, entry)
-
-	// Keep only last 15 entries
-	if len(history.Results) > 15 {
-		history.Results = history.Results[len(history.Results)-15:]
-	}
-
-	// Save to JSON file
-	data, err := json.MarshalIndent(history, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	err = os.WriteFile(jsonFilePath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write JSON file: %w", err)
+// SaveResultsToJSON appends the entries of a run to the JSON file in a single write, keeping the
+// last 15 entries or the whole run if it is longer
+func (o *Orchestrator) SaveResultsToJSON(entries []CheckResultEntry, jsonFilePath string, key *seal.Key) error {
+	history := &CheckResultsHistory{
+		Results: []CheckResultEntry{},
 	}
+	return jsonfile.Update(jsonFilePath, key, history, func() error {
+		history.Results = append(history.Results, entries...)
 
-	return nil
+		// Keep only last 15 entries, but never drop part of this run
+		if keep := max(15, len(entries)); len(history.Results) > keep {
+			history.Results = history.Results[len(history.Results)-keep:]
+		}
+		return nil
+	})
 }
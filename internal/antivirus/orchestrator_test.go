@@ -0,0 +1,26 @@
+package antivirus
+
+import "testing"
+
+func TestUploadFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"eicar.com", "eicar.com"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"/", ""},
+		{"../../.bashrc", ".bashrc"},
+		{"/etc/cron.d/eicar", "eicar"},
+		{`..\..\Startup\eicar.exe`, "eicar.exe"},
+		{"dir/..", ""},
+		{"eicar.com/", "eicar.com"},
+	}
+	for _, tt := range tests {
+		if got := uploadFileName(tt.name); got != tt.want {
+			t.Errorf("uploadFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,90 @@
 package antivirus
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// Verdict is the layered outcome of an antivirus delivery
+type Verdict string
+
+const (
+	VerdictNetworkBlocked  Verdict = "network_blocked"  // stopped by the gateway (block page or reset)
+	VerdictEndpointRemoved Verdict = "endpoint_removed" // delivered but removed on the endpoint
+	VerdictPersisted       Verdict = "persisted"        // delivered and still present on the endpoint
+	VerdictDelivered       Verdict = "delivered"        // upload accepted, no endpoint check applies
+	VerdictError           Verdict = "error"            // server unreachable or unexpected response
+	VerdictTimeout         Verdict = "timeout"          // no answer within the connect, response or overall deadline
+)
+
+// Control names reported to the dashboard, one per layer
+const (
+	ControlNetworkAntivirus  = "network_antivirus"
+	ControlEndpointAntivirus = "endpoint_antivirus"
+)
+
+// Control statuses reported to the dashboard
+const (
+	ControlEffective   = "effective"
+	ControlIneffective = "ineffective"
+	ControlNotTested   = "not_tested"
+	ControlError       = "error"
+)
 
 func EvaluateResult(resp *CheckResponse, err error) *Result {
 	if err != nil {
+		if isTimeout(err) {
+			return &Result{
+				IsVirusDetected: false,
+				Verdict:         VerdictTimeout,
+				StatusText:      fmt.Sprintf("Request timed out: %v", err),
+				IP:              "",
+				FileContent:     "",
+			}
+		}
+
+		if signature := resetSignature(err); signature != "" {
+			return &Result{
+				IsVirusDetected: true,
+				NetworkBlocked:  true,
+				Verdict:         VerdictNetworkBlocked,
+				BlockSignature:  signature,
+				StatusText:      fmt.Sprintf("Antivirus blocked request: %v", err),
+				IP:              "",
+				FileContent:     "",
+			}
+		}
+
+		return &Result{
+			IsVirusDetected: false,
+			Verdict:         VerdictError,
+			StatusText:      fmt.Sprintf("Request failed: %v", err),
+			IP:              "",
+			FileContent:     "",
+		}
+	}
+
+	if signature := matchBlockPage(resp); signature != "" {
 		return &Result{
 			IsVirusDetected: true,
-			StatusText:      fmt.Sprintf("Antivirus blocked request: %v", err),
+			NetworkBlocked:  true,
+			Verdict:         VerdictNetworkBlocked,
+			BlockSignature:  signature,
+			StatusText:      fmt.Sprintf("Antivirus block page returned: %s (%s)", resp.StatusText, signature),
+			IP:              "",
+			FileContent:     "",
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return &Result{
+			IsVirusDetected: false,
+			Verdict:         VerdictError,
+			StatusText:      fmt.Sprintf("Request failed: %s", resp.StatusText),
 			IP:              "",
 			FileContent:     "",
 		}
@@ -14,15 +92,116 @@ func EvaluateResult(resp *CheckResponse, err error) *Result {
 
 	return &Result{
 		IsVirusDetected: false,
+		Verdict:         VerdictDelivered,
 		StatusText:      fmt.Sprintf("Request succeeded: %s", resp.StatusText),
 		IP:              "",
 		FileContent:     "",
 	}
 }
 
+// isTimeout reports whether err is a deadline or network timeout. A timeout says
+// nothing about whether a control stopped the test.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
 
+// errReset marks a connection cut after the request was sent, which is how many gateways
+// stop a download once the payload has been scanned
+var errReset = errors.New("connection cut after the request was sent")
+
+// resetAfterRequest marks err with errReset when it resets or aborts a connection the request
+// was written on. Any other error, or a reset before the request was sent, is returned as it is.
+func resetAfterRequest(err error, written bool) error {
+	if written && isReset(err) {
+		return fmt.Errorf("%w: %w", errReset, err)
+	}
+	return err
+}
 
+// resetSignature returns a block signature when the connection was cut after the request was
+// sent. Everything else (DNS, refused, timeouts, closed connections) is an error, not a block.
+func resetSignature(err error) string {
+	switch {
+	case !errors.Is(err, errReset):
+		return ""
+	case errors.Is(err, syscall.ECONNABORTED), isWindowsErrno(err, wsaeconnaborted):
+		return "connection aborted"
+	}
+	return "connection reset"
+}
 
+// Winsock error numbers, which the syscall constants do not match on Windows
+const (
+	wsaeconnaborted = 10053
+	wsaeconnreset   = 10054
+)
 
+func isReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		isWindowsErrno(err, wsaeconnreset) || isWindowsErrno(err, wsaeconnaborted)
+}
 
+func isWindowsErrno(err error, errno syscall.Errno) bool {
+	var e syscall.Errno
+	return runtime.GOOS == "windows" && errors.As(err, &e) && e == errno
+}
 
+// Controls splits a result into one control per layer for the dashboard. Test cases expected
+// to be allowed check for false positives, not whether a layer stops malware, so their layers
+// are not tested; whether they passed is reported with the result. Deliveries that never left
+// the host only test the endpoint layer.
+func Controls(result *Result) []ControlResult {
+	network := ControlResult{Control: ControlNetworkAntivirus}
+	endpoint := ControlResult{Control: ControlEndpointAntivirus}
+
+	if result.EndpointOnly {
+		network.Status = ControlNotTested
+		network.Detail = "loopback delivery"
+		switch {
+		case result.Expected == ExpectAllowed && result.Verdict != VerdictError && result.Verdict != VerdictTimeout:
+			endpoint.Status = ControlNotTested
+		case result.Verdict == VerdictEndpointRemoved:
+			endpoint.Status = ControlEffective
+		case result.Verdict == VerdictPersisted:
+			endpoint.Status = ControlIneffective
+		case result.Verdict == VerdictError, result.Verdict == VerdictTimeout:
+			endpoint.Status = ControlError
+			endpoint.Detail = result.StatusText
+		default:
+			endpoint.Status = ControlNotTested
+		}
+		return []ControlResult{network, endpoint}
+	}
+
+	if result.Expected == ExpectAllowed && result.Verdict != VerdictError && result.Verdict != VerdictTimeout {
+		network.Status = ControlNotTested
+		endpoint.Status = ControlNotTested
+		return []ControlResult{network, endpoint}
+	}
+
+	switch result.Verdict {
+	case VerdictNetworkBlocked:
+		network.Status = ControlEffective
+		network.Detail = result.BlockSignature
+		endpoint.Status = ControlNotTested
+	case VerdictEndpointRemoved:
+		network.Status = ControlIneffective
+		endpoint.Status = ControlEffective
+	case VerdictPersisted:
+		network.Status = ControlIneffective
+		endpoint.Status = ControlIneffective
+	case VerdictDelivered:
+		network.Status = ControlIneffective
+		endpoint.Status = ControlNotTested
+	default:
+		network.Status = ControlError
+		network.Detail = result.StatusText
+		endpoint.Status = ControlNotTested
+	}
+
+	return []ControlResult{network, endpoint}
+}
@@ -0,0 +1,153 @@
+package antivirus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestEvaluateResultBlockPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      *CheckResponse
+		want      Verdict
+		signature string
+	}{
+		{
+			name: "known block page",
+			resp: &CheckResponse{StatusCode: 200, StatusText: "200 OK", ContentType: "text/html", Body: []byte("<h1>FortiGuard: virus blocked</h1>")},
+			want: VerdictNetworkBlocked,
+		},
+		{
+			name: "denial page",
+			resp: &CheckResponse{StatusCode: 403, StatusText: "403 Forbidden", ContentType: "text/html; charset=utf-8", Body: []byte("<h1>Access denied</h1>")},
+			want: VerdictNetworkBlocked,
+		},
+		{
+			name: "denial through a proxy",
+			resp: &CheckResponse{StatusCode: 451, StatusText: "451 Unavailable For Legal Reasons", ContentType: "text/plain", Via: "1.1 gateway", Body: []byte("denied")},
+			want: VerdictNetworkBlocked,
+		},
+		{
+			name: "denial from the test server",
+			resp: &CheckResponse{StatusCode: 403, StatusText: "403 Forbidden", ContentType: "application/json", Body: []byte(`{"error": "token expired"}`)},
+			want: VerdictError,
+		},
+		{
+			name: "test file mentioning a virus",
+			resp: &CheckResponse{StatusCode: 200, StatusText: "200 OK", ContentType: "text/plain", Body: []byte("virus detected")},
+			want: VerdictDelivered,
+		},
+		{
+			name:      "SquidClamav block page",
+			resp:      &CheckResponse{StatusCode: 200, StatusText: "200 OK", ContentType: "text/html", Body: []byte("<title>SquidClamav 7.1: Virus detected!</title>")},
+			want:      VerdictNetworkBlocked,
+			signature: "Squid / ClamAV",
+		},
+		{
+			name:      "c-icap block page",
+			resp:      &CheckResponse{StatusCode: 403, StatusText: "403 Forbidden", ContentType: "text/html", Body: []byte("<p>VIRUS FOUND: Eicar-Signature</p><p>c-icap/0.5.10</p>")},
+			want:      VerdictNetworkBlocked,
+			signature: "Squid / ClamAV",
+		},
+		{
+			name: "page mentioning ClamAV",
+			resp: &CheckResponse{StatusCode: 200, StatusText: "200 OK", ContentType: "text/html", Body: []byte("<h1>ClamAV</h1><p>ClamAV is an open source antivirus engine.</p>")},
+			want: VerdictDelivered,
+		},
+	}
+	for _, tt := range tests {
+		result := EvaluateResult(tt.resp, nil)
+		if result.Verdict != tt.want {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, result.Verdict, tt.want)
+		}
+		if tt.signature != "" && result.BlockSignature != tt.signature {
+			t.Errorf("%s: signature = %q, want %q", tt.name, result.BlockSignature, tt.signature)
+		}
+	}
+}
+
+func TestEvaluateResultError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Verdict
+	}{
+		{"reset after the request", fmt.Errorf("failed to read response body: %w", resetAfterRequest(syscall.ECONNRESET, true)), VerdictNetworkBlocked},
+		{"aborted after the request", resetAfterRequest(syscall.ECONNABORTED, true), VerdictNetworkBlocked},
+		{"reset before the request was sent", resetAfterRequest(syscall.ECONNRESET, false), VerdictError},
+		{"connection closed mid-transfer", resetAfterRequest(io.ErrUnexpectedEOF, true), VerdictError},
+		{"idle connection closed", errors.New("http: server closed idle connection"), VerdictError},
+		{"refused", syscall.ECONNREFUSED, VerdictError},
+		{"deadline", context.DeadlineExceeded, VerdictTimeout},
+	}
+	for _, tt := range tests {
+		if got := EvaluateResult(nil, tt.err).Verdict; got != tt.want {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendRequestReset(t *testing.T) {
+	// Reads the request and resets the connection, like a gateway stopping a download
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPClient(nil).SendRequest(context.Background(), &CheckRequest{TestURL: server.URL, HTTPMethod: "GET"})
+	if result := EvaluateResult(nil, err); result.Verdict != VerdictNetworkBlocked || result.BlockSignature != "connection reset" {
+		t.Errorf("verdict = %s (%s), want %s with connection reset", result.Verdict, result.StatusText, VerdictNetworkBlocked)
+	}
+}
+
+func TestControls(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   Result
+		network  string
+		endpoint string
+	}{
+		{"blocked at the gateway", Result{Expected: ExpectBlocked, Verdict: VerdictNetworkBlocked}, ControlEffective, ControlNotTested},
+		{"removed on the endpoint", Result{Expected: ExpectBlocked, Verdict: VerdictEndpointRemoved}, ControlIneffective, ControlEffective},
+		{"persisted", Result{Expected: ExpectBlocked, Verdict: VerdictPersisted}, ControlIneffective, ControlIneffective},
+		{"allowed and persisted", Result{Expected: ExpectAllowed, Verdict: VerdictPersisted}, ControlNotTested, ControlNotTested},
+		{"allowed but blocked", Result{Expected: ExpectAllowed, Verdict: VerdictNetworkBlocked}, ControlNotTested, ControlNotTested},
+		{"allowed, server unreachable", Result{Expected: ExpectAllowed, Verdict: VerdictError}, ControlError, ControlNotTested},
+		{"loopback, removed", Result{Expected: ExpectBlocked, Verdict: VerdictEndpointRemoved, EndpointOnly: true}, ControlNotTested, ControlEffective},
+		{"loopback, persisted", Result{Expected: ExpectBlocked, Verdict: VerdictPersisted, EndpointOnly: true}, ControlNotTested, ControlIneffective},
+		{"loopback, failed", Result{Expected: ExpectBlocked, Verdict: VerdictError, EndpointOnly: true}, ControlNotTested, ControlError},
+		{"loopback, allowed", Result{Expected: ExpectAllowed, Verdict: VerdictPersisted, EndpointOnly: true}, ControlNotTested, ControlNotTested},
+	}
+	for _, tt := range tests {
+		controls := Controls(&tt.result)
+		if len(controls) != 2 || controls[0].Status != tt.network || controls[1].Status != tt.endpoint {
+			t.Errorf("%s: controls = %+v, want network %s, endpoint %s", tt.name, controls, tt.network, tt.endpoint)
+		}
+	}
+}
+
+func TestDeliverMailStandIn(t *testing.T) {
+	for _, mode := range []string{DeliveryIMAP, DeliveryPOP3} {
+		tc := AntivirusAPIData{File: "eicar.txt", FileContent: "test file"}
+		resp, err := deliverMail(context.Background(), tc, mode)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if !resp.Loopback || string(resp.Body) != tc.FileContent {
+			t.Errorf("%s: response = %+v, want the file content served over loopback", mode, resp)
+		}
+	}
+}
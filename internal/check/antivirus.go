@@ -0,0 +1,212 @@
+package check
+
+import (
+	"context"
+	"fmt"
+
+	"dlpagent/internal/antivirus"
+	"dlpagent/internal/config"
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/history"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/redact"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// Antivirus runs the antivirus test cases
+type Antivirus struct {
+	cfg *config.Config
+	env Env
+}
+
+func NewAntivirus(cfg *config.Config, env Env) *Antivirus {
+	return &Antivirus{cfg: cfg, env: env}
+}
+
+func (c *Antivirus) ID() string                 { return "antivirus" }
+func (c *Antivirus) Category() summary.Category { return summary.CategoryAntivirus }
+func (c *Antivirus) Config() config.CheckConfig { return c.cfg.Antivirus.CheckConfig }
+func (c *Antivirus) Endpoint() string           { return dashboard.AntivirusEndpoint }
+
+func (c *Antivirus) catalog(ctx context.Context) ([]antivirus.AntivirusAPIData, error) {
+	client := antivirus.NewHTTPClient(c.env.ServerHTTPClient)
+	client.Verifier = c.env.Settings.Verifier
+	return client.GetAntivirusCatalog(ctx, c.env.Settings.ServerURL()+antivirus.CatalogPath)
+}
+
+func (c *Antivirus) Run(ctx context.Context) *Result {
+	run := newResult(ctx, c)
+	orchestrator := antivirus.NewOrchestrator(c.env.HTTPClient)
+
+	// Prefer the server-driven catalog, fall back to the single download URL from settings
+	var results []*antivirus.Result
+	catalog, err := c.catalog(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Failed to fetch antivirus catalog: %v\n", err)
+	}
+	var downloadURL string
+	if len(catalog) == 0 {
+		if s, err := c.env.settings(ctx); err != nil {
+			fmt.Printf("Error: Failed to get settings: %v\n", err)
+			run.add(summary.Error(summary.CategoryAntivirus, "settings", err))
+		} else {
+			downloadURL = s.URLAntivirus
+		}
+	}
+
+	// When comparing, only the HTTP test cases are repeated directly
+	for i, route := range c.env.routes() {
+		if ctx.Err() != nil {
+			break
+		}
+		cases := catalog
+		if i > 0 {
+			cases = antivirus.HTTPCases(catalog)
+		}
+
+		var routeResults []*antivirus.Result
+		routeOrchestrator := antivirus.NewOrchestrator(route.client)
+		if len(cases) > 0 {
+			fmt.Printf("Running %d antivirus test case(s) from catalog%s\n", len(cases), route.suffix())
+			routeResults = routeOrchestrator.RunCatalog(ctx, cases)
+		} else if downloadURL != "" {
+			result := routeOrchestrator.RunAntivirusCheck(ctx, downloadURL)
+			if ctx.Err() == nil {
+				routeResults = []*antivirus.Result{result}
+			}
+		}
+		for _, result := range routeResults {
+			result.Route = route.name
+		}
+		results = append(results, routeResults...)
+	}
+
+	failed := 0
+	compared := newComparison()
+	entries := make([]antivirus.CheckResultEntry, 0, len(results))
+	records := make([]store.Record, 0, len(results))
+	for i, result := range results {
+		run.add(summary.Antivirus(result))
+		if result.Route != "" {
+			switch result.Verdict {
+			case antivirus.VerdictError, antivirus.VerdictTimeout:
+			default:
+				name := result.TestID
+				if name == "" {
+					name = "download check"
+				}
+				compared.add(name, result.Route, result.NetworkBlocked || result.EndpointRemoved)
+			}
+		}
+
+		entry := antivirus.NewCheckResultEntry(result)
+		entry.FileContent = redact.Content(c.cfg.Store.FileContent, redact.CategoryMalware, entry.FileContent)
+		entries = append(entries, entry)
+		records = append(records, record(antivirusEntry(entry), entry))
+
+		fmt.Printf("\n[%d/%d] ", i+1, len(results))
+		if result.TestID != "" {
+			fmt.Printf("Test case: %s (%s %s, expected %s)\n", summary.WithRoute(result.TestID, result.Route), result.Delivery, result.Method, result.Expected)
+		} else {
+			fmt.Println(summary.WithRoute("Antivirus download check", result.Route))
+		}
+		fmt.Printf("Virus Detected: %v\n", result.IsVirusDetected)
+		fmt.Printf("Verdict: %s\n", result.Verdict)
+		if result.BlockSignature != "" {
+			fmt.Printf("Block Signature: %s\n", result.BlockSignature)
+		}
+		for _, control := range antivirus.Controls(result) {
+			fmt.Printf("Control %s: %s\n", control.Control, control.Status)
+		}
+		fmt.Printf("Status: %s\n", result.StatusText)
+		if result.FileName != "" {
+			fmt.Printf("File Name: %s\n", result.FileName)
+		}
+		if result.FilePath != "" {
+			fmt.Printf("File Exists: %v\n", result.FileExists)
+			fmt.Printf("File Path: %s\n", result.FilePath)
+		}
+
+		if result.TestID != "" {
+			if result.Passed {
+				fmt.Println("✅ Outcome matches expectation")
+			} else {
+				fmt.Println("❌ Outcome does not match expectation")
+				failed++
+			}
+		} else if result.IsVirusDetected {
+			fmt.Println("\n❌ Antivirus check FAILED: Virus detected!")
+		} else {
+			fmt.Println("\n✅ Antivirus check PASSED")
+		}
+	}
+
+	// Save the results of the run to the JSON file at once
+	if len(entries) > 0 {
+		if err := orchestrator.SaveResultsToJSON(entries, c.cfg.Antivirus.JSON, c.env.Key); err != nil {
+			fmt.Printf("Warning: Failed to save antivirus results to JSON: %v\n", err)
+		}
+		c.env.save(run, records)
+	}
+
+	if len(catalog) > 0 {
+		fmt.Printf("\nAntivirus: %d/%d test case(s) matched expectation\n", len(results)-failed, len(results))
+	}
+	if c.env.DirectHTTPClient != nil {
+		compared.print()
+	}
+	if ctx.Err() != nil {
+		run.interrupted(ctx, c.Category())
+	}
+
+	return run.finish()
+}
+
+// Verify fetches the test-case catalog and checks that delivered files can be saved
+func (c *Antivirus) Verify(ctx context.Context) []Verification {
+	var steps []Verification
+	if catalog, err := c.catalog(ctx); err != nil {
+		steps = append(steps, Verification{Name: "Antivirus catalog", Err: err})
+	} else {
+		steps = append(steps, Verification{Name: fmt.Sprintf("Antivirus catalog (%d test cases)", len(catalog))})
+	}
+	return append(steps, Verification{Name: "Uploads directory writable", Err: DirWritable("uploads")})
+}
+
+func (c *Antivirus) Records() ([]store.Record, error) {
+	var h antivirus.CheckResultsHistory
+	if err := jsonfile.Read(c.cfg.Antivirus.JSON, c.env.Key, &h); err != nil {
+		return nil, err
+	}
+	records := make([]store.Record, 0, len(h.Results))
+	for _, r := range h.Results {
+		records = append(records, record(antivirusEntry(r), r))
+	}
+	return records, nil
+}
+
+// antivirusEntry returns the history entry of a stored result, with the status the summary
+// gives the result, so the history and the summary of a run always agree
+func antivirusEntry(r antivirus.CheckResultEntry) history.Entry {
+	outcome := summary.Antivirus(&antivirus.Result{
+		StatusText:      r.StatusText,
+		FileName:        r.FileName,
+		TestID:          r.TestID,
+		Passed:          r.Passed,
+		NetworkBlocked:  r.NetworkBlocked,
+		EndpointRemoved: r.EndpointRemoved,
+		Verdict:         r.Verdict,
+		Route:           r.Route,
+	})
+	return history.Entry{
+		Timestamp: r.Timestamp,
+		Check:     "antivirus",
+		Category:  outcome.Category,
+		Name:      outcome.Name,
+		Status:    outcome.Status,
+		Verdict:   string(r.Verdict),
+		Detail:    r.StatusText,
+		IP:        r.IP,
+	}
+}
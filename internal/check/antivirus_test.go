@@ -0,0 +1,27 @@
+package check
+
+import (
+	"testing"
+
+	"dlpagent/internal/antivirus"
+	"dlpagent/internal/summary"
+)
+
+func TestAntivirusEntryStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry antivirus.CheckResultEntry
+		want  summary.Status
+	}{
+		{"blocked download", antivirus.CheckResultEntry{Verdict: antivirus.VerdictNetworkBlocked, IsVirusDetected: true, NetworkBlocked: true}, summary.StatusEffective},
+		{"detected but persisted", antivirus.CheckResultEntry{Verdict: antivirus.VerdictPersisted, IsVirusDetected: true}, summary.StatusFailed},
+		{"passed test case", antivirus.CheckResultEntry{TestID: "eicar", Verdict: antivirus.VerdictEndpointRemoved, Passed: true}, summary.StatusEffective},
+		{"failed test case", antivirus.CheckResultEntry{TestID: "eicar", Verdict: antivirus.VerdictPersisted}, summary.StatusFailed},
+		{"timeout", antivirus.CheckResultEntry{TestID: "eicar", Verdict: antivirus.VerdictTimeout}, summary.StatusTimeout},
+	}
+	for _, tt := range tests {
+		if got := antivirusEntry(tt.entry).Status; got != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/history"
+	"dlpagent/internal/host"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/settings"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// Check is a control test. The scheduler, the CLI, history and the dashboard upload
+// work against the checks in a Registry, so a new test only has to implement Check.
+type Check interface {
+	// ID names the check in configuration keys, server settings, the CLI and the dashboard
+	ID() string
+	// Category is the summary category of the check; a run may report outcomes in others too
+	Category() summary.Category
+	// Config returns the schedule and results file of the check
+	Config() config.CheckConfig
+	// Endpoint is the dashboard endpoint the stored results of the check are uploaded to
+	Endpoint() string
+	// Run runs the check once and stores its results
+	Run(ctx context.Context) *Result
+
+	// Records reads the results file, which is imported into the result database once, with
+	// each entry of the file as the data of its record
+	Records() ([]store.Record, error)
+}
+
+// Result is the common result of a single run of a check
+type Result struct {
+	Check    string
+	Started  time.Time
+	Finished time.Time
+	Host     *host.Facts // the host at the start of the run
+	Outcomes []summary.Outcome
+}
+
+func newResult(ctx context.Context, c Check) *Result {
+	return &Result{Check: c.ID(), Started: time.Now(), Host: host.Collect(ctx)}
+}
+
+func (r *Result) add(outcomes ...summary.Outcome) {
+	r.Outcomes = append(r.Outcomes, outcomes...)
+}
+
+// interrupted records that the run was stopped by the check deadline or by shutdown
+// before every test had run
+func (r *Result) interrupted(ctx context.Context, category summary.Category) {
+	fmt.Printf("\n%s check interrupted: %v\n", r.Check, ctx.Err())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		r.add(summary.Timeout(category, r.Check+" check", errors.New("check deadline exceeded")))
+		return
+	}
+	r.add(summary.Error(category, r.Check+" check", ctx.Err()))
+}
+
+func (r *Result) finish() *Result {
+	r.Finished = time.Now()
+	return r
+}
+
+// Verifier is implemented by checks that can verify their prerequisites without running
+type Verifier interface {
+	Verify(ctx context.Context) []Verification
+}
+
+// Verification is a single step of a Verify
+type Verification struct {
+	Name string
+	Err  error
+}
+
+// Env is what checks need from the running agent
+type Env struct {
+	Settings         *settings.Client
+	ServerHTTPClient *http.Client // client for the dashboard server
+	HTTPClient       *http.Client // client for test traffic, with the configured timeouts and proxy
+	// DirectHTTPClient bypasses the proxy; it is set when test traffic is compared with and without it
+	DirectHTTPClient *http.Client
+	Store            *store.Store // result database; results are only written to the results files without it
+	Key              *seal.Key    // encrypts the results files; nil writes them in plain text
+}
+
+// save adds the results of a run to the result database, with the host they were produced on
+func (e Env) save(run *Result, records []store.Record) {
+	if e.Store == nil || len(records) == 0 {
+		return
+	}
+	if run.Host != nil {
+		// The description is stored once for all results of the same host; the facts always encode
+		data, _ := json.Marshal(run.Host)
+		hostID, err := e.Store.AddHost(data)
+		if err != nil {
+			fmt.Printf("Warning: Failed to store host facts: %v\n", err)
+		}
+		for i := range records {
+			records[i].HostID = hostID
+		}
+	}
+	if err := e.Store.Add(records...); err != nil {
+		fmt.Printf("Warning: Failed to store results: %v\n", err)
+	}
+}
+
+// record returns the database record of a result in its common and its check-specific form
+func record(entry history.Entry, data interface{}) store.Record {
+	// The stored result types always encode
+	raw, _ := json.Marshal(data)
+	return store.Record{Entry: entry, Data: raw}
+}
+
+// route is a path test traffic takes to its target
+type route struct {
+	name   string // "proxy" or "direct" when the paths are compared, otherwise empty
+	client *http.Client
+}
+
+// suffix labels output of a compared route
+func (r route) suffix() string {
+	if r.name == "" {
+		return ""
+	}
+	return " via " + r.name
+}
+
+// routes returns the paths to send test traffic on
+func (e Env) routes() []route {
+	if e.DirectHTTPClient == nil {
+		return []route{{client: e.HTTPClient}}
+	}
+	return []route{{name: "proxy", client: e.HTTPClient}, {name: "direct", client: e.DirectHTTPClient}}
+}
+
+// settings returns the agent settings, warning when the last known good copy is used
+func (e Env) settings(ctx context.Context) (*settings.Settings, error) {
+	s, err := e.Settings.Get(ctx)
+	if s == nil {
+		return nil, err
+	}
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return s, nil
+}
+
+// FileWritable reports whether path can be written without changing an existing file
+func FileWritable(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	return DirWritable(filepath.Dir(path))
+}
+
+// DirWritable reports whether files can be created in dir, creating it if needed
+func DirWritable(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".dlpagent-selftest-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,50 @@
+package check
+
+import "fmt"
+
+// comparison records whether each test was stopped through the proxy and directly
+type comparison struct {
+	names   []string
+	stopped map[string]map[string]bool // test name, route
+}
+
+func newComparison() *comparison {
+	return &comparison{stopped: map[string]map[string]bool{}}
+}
+
+// add records the outcome of a test on a route. Tests without a clear outcome are left out.
+func (c *comparison) add(name, route string, stopped bool) {
+	if c.stopped[name] == nil {
+		c.names = append(c.names, name)
+		c.stopped[name] = map[string]bool{}
+	}
+	c.stopped[name][route] = stopped
+}
+
+// print shows the tests stopped only through the proxy, which users can bypass by going direct
+func (c *comparison) print() {
+	fmt.Println("\n=== Proxy vs direct ===")
+	bypassed := 0
+	for _, name := range c.names {
+		routes := c.stopped[name]
+		viaProxy, proxyOK := routes["proxy"]
+		direct, directOK := routes["direct"]
+		if !proxyOK || !directOK {
+			continue
+		}
+		switch {
+		case viaProxy && !direct:
+			fmt.Printf("❌ %s: stopped through the proxy, NOT stopped directly\n", name)
+			bypassed++
+		case viaProxy && direct:
+			fmt.Printf("✅ %s: stopped through the proxy and directly\n", name)
+		case !viaProxy && direct:
+			fmt.Printf("✅ %s: stopped directly only\n", name)
+		default:
+			fmt.Printf("❌ %s: not stopped on either path\n", name)
+		}
+	}
+	if bypassed > 0 {
+		fmt.Printf("⚠️  %d test(s) can bypass inspection by not using the proxy\n", bypassed)
+	}
+}
@@ -0,0 +1,242 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/dlp"
+	"dlpagent/internal/history"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/redact"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// DLP sends files with sensitive data to the DLP test URL
+type DLP struct {
+	cfg *config.Config
+	env Env
+}
+
+func NewDLP(cfg *config.Config, env Env) *DLP {
+	return &DLP{cfg: cfg, env: env}
+}
+
+func (c *DLP) ID() string                 { return "dlp" }
+func (c *DLP) Category() summary.Category { return summary.CategoryDLP }
+func (c *DLP) Config() config.CheckConfig { return c.cfg.DLP.CheckConfig }
+func (c *DLP) Endpoint() string           { return dashboard.DLPEndpoint }
+
+// Files returns the configured test files, otherwise the ones from the server settings.
+// Without either the generated samples are used.
+func (c *DLP) Files() []string {
+	if len(c.cfg.DLP.Files) > 0 {
+		return c.cfg.DLP.Files
+	}
+	if s := c.env.Settings.Current(); s != nil {
+		return serverFiles(s.DLPFiles, c.cfg.DLP.SamplesDir)
+	}
+	return nil
+}
+
+// serverFiles returns the test files named by the server that the agent may send: the generated
+// samples, and files directly inside samplesDir. Only the base name of an entry is used, so the
+// server cannot make the agent upload any other file on the endpoint.
+func serverFiles(names []string, samplesDir string) []string {
+	var files []string
+	for _, name := range names {
+		base := filepath.Base(filepath.Clean(name))
+		switch {
+		case base == "." || base == ".." || base == string(filepath.Separator):
+			// Not a file name
+		case slices.Contains(dlp.SampleFiles, base):
+			files = append(files, base)
+			continue
+		case samplesDir != "":
+			if file, ok := sampleFile(samplesDir, base); ok {
+				files = append(files, file)
+				continue
+			}
+		}
+		fmt.Printf("Warning: Ignoring DLP test file %q from the server settings: not a sample file\n", name)
+	}
+	return files
+}
+
+// sampleFile returns the file named base in dir, if it does not lead out of dir through a symlink
+func sampleFile(dir, base string) (string, bool) {
+	file := filepath.Join(dir, base)
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", false
+	}
+	resolved, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		// A missing file is reported when the check runs
+		return file, os.IsNotExist(err)
+	}
+	return file, filepath.Dir(resolved) == resolvedDir
+}
+
+func (c *DLP) Run(ctx context.Context) *Result {
+	run := newResult(ctx, c)
+
+	// Use the configured URL, otherwise the one from settings
+	settingUrl := c.cfg.DLP.URL
+	if settingUrl == "" {
+		s, err := c.env.settings(ctx)
+		if err != nil {
+			fmt.Printf("Error: Failed to get settings: %v\n", err)
+			run.add(summary.Error(summary.CategoryDLP, "settings", err))
+			return run.finish()
+		}
+		settingUrl = s.URLDLP
+	}
+	if settingUrl == "" {
+		fmt.Println("Error: DLP URL is required")
+		run.add(summary.Error(summary.CategoryDLP, "settings", errors.New("DLP URL is required")))
+		return run.finish()
+	}
+
+	// Missing files are reported up front; the rest are sent by the worker pool
+	var files []string
+	for _, file := range dlp.PrepareFiles(c.Files()) {
+		if _, err := os.Stat(file); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			run.add(summary.Error(summary.CategoryDLP, file, err))
+			continue
+		}
+		files = append(files, file)
+	}
+
+	options := dlp.PoolOptions{Concurrency: c.cfg.DLP.Concurrency, RateLimit: c.cfg.DLP.RateLimit}
+	var orchestrator *dlp.Orchestrator
+	var results []dlp.FileResult
+	var hasError bool
+	compared := newComparison()
+
+	for _, route := range c.env.routes() {
+		if ctx.Err() != nil {
+			break
+		}
+		if route.name != "" {
+			fmt.Printf("\n=== DLP %s ===\n", route.name)
+		}
+		orchestrator = dlp.NewOrchestrator(route.client)
+		orchestrator.RunFiles(ctx, files, settingUrl, c.cfg.DLP.Method, options, func(r dlp.FileResult) {
+			r.Result.Route = route.name
+			results = append(results, r)
+			run.add(summary.DLP(r.File, r.Result))
+			if !r.Result.TimedOut && !r.Result.Error {
+				compared.add(r.File, route.name, r.Result.IsDLPActive)
+			}
+			if c.printFileResult(r, len(files)) {
+				hasError = true
+			}
+		})
+	}
+	if c.env.DirectHTTPClient != nil {
+		compared.print()
+	}
+
+	// Save the results of the run to the JSON file at once
+	if len(results) > 0 {
+		entries := make([]dlp.CheckResultEntry, 0, len(results))
+		records := make([]store.Record, 0, len(results))
+		for _, r := range results {
+			entry := dlp.NewCheckResultEntry(r)
+			entry.FileContent = redact.Content(c.cfg.Store.FileContent, entry.Category, entry.FileContent)
+			entries = append(entries, entry)
+			records = append(records, record(dlpEntry(entry), entry))
+		}
+		if err := orchestrator.SaveResultsToJSON(entries, c.cfg.DLP.JSON, c.env.Key); err != nil {
+			fmt.Printf("Warning: Failed to save DLP results to JSON: %v\n", err)
+		}
+		c.env.save(run, records)
+	}
+
+	if ctx.Err() != nil {
+		run.interrupted(ctx, c.Category())
+	} else if !hasError {
+		fmt.Printf("\n✅ All DLP files processed successfully. No DLP detected.\n")
+	}
+
+	return run.finish()
+}
+
+// printFileResult prints the result of a file and reports whether DLP stopped it, it timed out or it failed
+func (c *DLP) printFileResult(r dlp.FileResult, total int) bool {
+	name := summary.WithRoute(r.File, r.Result.Route)
+	fmt.Printf("\n[%d/%d] Processing file: %s\n", r.Index+1, total, name)
+	fmt.Printf("DLP Active: %v\n", r.Result.IsDLPActive)
+	fmt.Printf("Status: %s\n", r.Result.StatusText)
+
+	if r.Result.TimedOut {
+		fmt.Printf("⏱️  Request timed out for file: %s\n", name)
+		return true
+	}
+	if r.Result.Error {
+		fmt.Printf("⚠️  DLP not tested for file: %s\n", name)
+		return true
+	}
+	if r.Result.IsDLPActive {
+		fmt.Printf("❌ DLP detected in file: %s\n", name)
+		return true
+	}
+	return false
+}
+
+// Verify checks that the test files exist or the samples can be created
+func (c *DLP) Verify(ctx context.Context) []Verification {
+	files := c.Files()
+	if len(files) == 0 {
+		// The generated samples are created in the working directory
+		return []Verification{{Name: "DLP sample files can be created", Err: DirWritable(".")}}
+	}
+
+	steps := make([]Verification, 0, len(files))
+	for _, file := range files {
+		_, err := os.Stat(file)
+		steps = append(steps, Verification{Name: "DLP test file: " + file, Err: err})
+	}
+	return steps
+}
+
+func (c *DLP) Records() ([]store.Record, error) {
+	var h dlp.CheckResultsHistory
+	if err := jsonfile.Read(c.cfg.DLP.JSON, c.env.Key, &h); err != nil {
+		return nil, err
+	}
+	records := make([]store.Record, 0, len(h.Results))
+	for _, r := range h.Results {
+		records = append(records, record(dlpEntry(r), r))
+	}
+	return records, nil
+}
+
+func dlpEntry(r dlp.CheckResultEntry) history.Entry {
+	entry := history.Entry{
+		Timestamp: r.Timestamp,
+		Check:     "dlp",
+		Category:  summary.CategoryDLP,
+		Name:      summary.WithRoute(r.FileName, r.Route),
+		Status:    summary.StatusFailed,
+		Detail:    r.StatusText,
+		IP:        r.IP,
+	}
+	switch {
+	case r.TimedOut:
+		entry.Status = summary.StatusTimeout
+	case r.Error:
+		entry.Status = summary.StatusError
+	case r.IsDLPActive:
+		entry.Status = summary.StatusEffective
+	}
+	return entry
+}
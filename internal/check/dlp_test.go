@@ -0,0 +1,61 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestServerFiles(t *testing.T) {
+	dir := t.TempDir()
+	samples := filepath.Join(dir, "samples")
+	if err := os.Mkdir(samples, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"passport.txt", "secret"} {
+		path := filepath.Join(samples, name)
+		if name == "secret" {
+			path = filepath.Join(dir, name)
+		}
+		if err := os.WriteFile(path, []byte("AB1234567"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(samples, "link.txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		entry      string
+		samplesDir string
+		want       []string
+	}{
+		{"generated sample", "test_credit_card.txt", "", []string{"test_credit_card.txt"}},
+		{"generated sample with a path", "/etc/test_credit_card.txt", "", []string{"test_credit_card.txt"}},
+		{"absolute path", "/etc/shadow", "", nil},
+		{"traversal", "../../.ssh/id_rsa", "", nil},
+		{"sample directory", "passport.txt", samples, []string{filepath.Join(samples, "passport.txt")}},
+		{"path into the sample directory", filepath.Join(samples, "passport.txt"), samples, []string{filepath.Join(samples, "passport.txt")}},
+		{"outside the sample directory", filepath.Join(dir, "secret"), samples, []string{filepath.Join(samples, "secret")}},
+		{"parent of the sample directory", "..", samples, nil},
+		{"root", "/", samples, nil},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, struct {
+			name       string
+			entry      string
+			samplesDir string
+			want       []string
+		}{"symlink out of the sample directory", "link.txt", samples, nil})
+	}
+	for _, tt := range tests {
+		if got := serverFiles([]string{tt.entry}, tt.samplesDir); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: serverFiles(%q) = %q, want %q", tt.name, tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/edr"
+	"dlpagent/internal/history"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// EDR runs the EDR behaviour simulations, each in its own sandbox
+type EDR struct {
+	cfg *config.Config
+	env Env
+}
+
+func NewEDR(cfg *config.Config, env Env) *EDR {
+	return &EDR{cfg: cfg, env: env}
+}
+
+func (c *EDR) ID() string                 { return "edr" }
+func (c *EDR) Category() summary.Category { return summary.CategoryEDR }
+func (c *EDR) Config() config.CheckConfig { return c.cfg.EDR.CheckConfig }
+func (c *EDR) Endpoint() string           { return dashboard.EDREndpoint }
+
+func (c *EDR) Run(ctx context.Context) *Result {
+	run := newResult(ctx, c)
+
+	options := edr.DefaultOptions()
+	options.Techniques = c.cfg.EDR.Techniques
+	orchestrator := edr.NewOrchestrator(options)
+	results := orchestrator.RunSimulations(ctx)
+
+	failed := 0
+	entries := make([]edr.CheckResultEntry, 0, len(results))
+	records := make([]store.Record, 0, len(results))
+	for i, result := range results {
+		run.add(summary.EDR(result))
+
+		entry := edr.NewCheckResultEntry(result)
+		entries = append(entries, entry)
+		records = append(records, record(edrEntry(entry), entry))
+
+		fmt.Printf("\n[%d/%d] EDR simulation: %s %s (%s)\n", i+1, len(results), result.TechniqueID, result.Name, result.Tactic)
+		fmt.Printf("Verdict: %s\n", result.Verdict)
+		fmt.Printf("Status: %s\n", result.StatusText)
+
+		switch result.Verdict {
+		case edr.VerdictPrevented:
+			fmt.Println("✅ Simulation stopped by EDR")
+		case edr.VerdictSkipped:
+			fmt.Println("⏭️  Skipped on this platform")
+		case edr.VerdictExecuted:
+			fmt.Println("❌ Simulation NOT stopped by EDR")
+			failed++
+		default:
+			fmt.Println("⚠️  Simulation could not be run")
+		}
+	}
+
+	// Save the results of the run to the JSON file at once
+	if len(entries) > 0 {
+		if err := orchestrator.SaveResultsToJSON(entries, c.cfg.EDR.JSON, c.env.Key); err != nil {
+			fmt.Printf("Warning: Failed to save EDR results to JSON: %v\n", err)
+		}
+		c.env.save(run, records)
+	}
+
+	fmt.Printf("\nEDR: %d/%d simulation(s) not stopped\n", failed, len(results))
+	if ctx.Err() != nil {
+		run.interrupted(ctx, c.Category())
+	}
+
+	return run.finish()
+}
+
+// Verify checks that the simulation sandboxes can be created
+func (c *EDR) Verify(ctx context.Context) []Verification {
+	return []Verification{{Name: "EDR sandbox directory: " + os.TempDir(), Err: DirWritable(os.TempDir())}}
+}
+
+func (c *EDR) Records() ([]store.Record, error) {
+	var h edr.CheckResultsHistory
+	if err := jsonfile.Read(c.cfg.EDR.JSON, c.env.Key, &h); err != nil {
+		return nil, err
+	}
+	records := make([]store.Record, 0, len(h.Results))
+	for _, r := range h.Results {
+		records = append(records, record(edrEntry(r), r))
+	}
+	return records, nil
+}
+
+// edrEntry returns the history entry of a stored result, with the status the summary gives the
+// result, so the history and the summary of a run always agree
+func edrEntry(r edr.CheckResultEntry) history.Entry {
+	outcome := summary.EDR(&edr.Result{
+		TechniqueID: r.TechniqueID,
+		Name:        r.Name,
+		Verdict:     r.Verdict,
+		StatusText:  r.StatusText,
+	})
+	return history.Entry{
+		Timestamp: r.Timestamp,
+		Check:     "edr",
+		Category:  outcome.Category,
+		Name:      outcome.Name,
+		Status:    outcome.Status,
+		Verdict:   string(r.Verdict),
+		Detail:    r.StatusText,
+		IP:        r.IP,
+	}
+}
@@ -0,0 +1,27 @@
+package check
+
+import (
+	"testing"
+
+	"dlpagent/internal/edr"
+	"dlpagent/internal/summary"
+)
+
+func TestEDREntryStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry edr.CheckResultEntry
+		want  summary.Status
+	}{
+		{"prevented", edr.CheckResultEntry{TechniqueID: "T1053.003", Verdict: edr.VerdictPrevented}, summary.StatusEffective},
+		{"executed", edr.CheckResultEntry{TechniqueID: "T1053.003", Verdict: edr.VerdictExecuted}, summary.StatusFailed},
+		{"skipped", edr.CheckResultEntry{TechniqueID: "T1059.004", Verdict: edr.VerdictSkipped}, summary.StatusSkipped},
+		{"error", edr.CheckResultEntry{TechniqueID: "T1003.008", Verdict: edr.VerdictError}, summary.StatusError},
+	}
+	for _, tt := range tests {
+		entry := edrEntry(tt.entry)
+		if entry.Status != tt.want || entry.Category != summary.CategoryEDR || entry.Check != "edr" {
+			t.Errorf("%s: entry = %s %s %s, want edr %s %s", tt.name, entry.Check, entry.Category, entry.Status, summary.CategoryEDR, tt.want)
+		}
+	}
+}
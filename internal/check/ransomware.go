@@ -0,0 +1,105 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/history"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/ransomware"
+	"dlpagent/internal/store"
+	"dlpagent/internal/summary"
+)
+
+// Ransomware runs the ransomware simulation in a sandbox of dummy files
+type Ransomware struct {
+	cfg *config.Config
+	env Env
+}
+
+func NewRansomware(cfg *config.Config, env Env) *Ransomware {
+	return &Ransomware{cfg: cfg, env: env}
+}
+
+func (c *Ransomware) ID() string                 { return "ransomware" }
+func (c *Ransomware) Category() summary.Category { return summary.CategoryRansomware }
+func (c *Ransomware) Config() config.CheckConfig { return c.cfg.Ransomware.CheckConfig }
+func (c *Ransomware) Endpoint() string           { return dashboard.RansomwareEndpoint }
+
+func (c *Ransomware) Run(ctx context.Context) *Result {
+	run := newResult(ctx, c)
+
+	options := ransomware.DefaultOptions()
+	options.BaseDir = c.cfg.Ransomware.Dir
+	orchestrator := ransomware.NewOrchestrator(options)
+	result := orchestrator.RunRansomwareCheck(ctx)
+	if ctx.Err() != nil {
+		run.interrupted(ctx, c.Category())
+		return run.finish()
+	}
+	run.add(summary.Ransomware(result))
+
+	// Save result to JSON file
+	entry := ransomware.NewCheckResultEntry(result)
+	if err := orchestrator.SaveResultToJSON(entry, c.cfg.Ransomware.JSON, c.env.Key); err != nil {
+		fmt.Printf("Warning: Failed to save ransomware result to JSON: %v\n", err)
+	}
+	c.env.save(run, []store.Record{record(ransomwareEntry(entry), entry)})
+
+	fmt.Printf("Verdict: %s\n", result.Verdict)
+	fmt.Printf("Status: %s\n", result.StatusText)
+	fmt.Printf("Files Encrypted: %d/%d\n", result.FilesEncrypted, result.FilesCreated)
+	fmt.Printf("Files Restored: %d\n", result.FilesRestored)
+
+	if result.IsEDRActive() {
+		fmt.Println("\n✅ Ransomware simulation stopped by EDR")
+	} else {
+		fmt.Println("\n❌ Ransomware simulation NOT stopped by EDR")
+	}
+
+	return run.finish()
+}
+
+// Verify checks that the sandbox can be created
+func (c *Ransomware) Verify(ctx context.Context) []Verification {
+	dir := c.cfg.Ransomware.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return []Verification{{Name: "Ransomware sandbox directory: " + dir, Err: DirWritable(dir)}}
+}
+
+func (c *Ransomware) Records() ([]store.Record, error) {
+	var h ransomware.CheckResultsHistory
+	if err := jsonfile.Read(c.cfg.Ransomware.JSON, c.env.Key, &h); err != nil {
+		return nil, err
+	}
+	records := make([]store.Record, 0, len(h.Results))
+	for _, r := range h.Results {
+		records = append(records, record(ransomwareEntry(r), r))
+	}
+	return records, nil
+}
+
+func ransomwareEntry(r ransomware.CheckResultEntry) history.Entry {
+	entry := history.Entry{
+		Timestamp: r.Timestamp,
+		Check:     "ransomware",
+		Category:  summary.CategoryRansomware,
+		Name:      "ransomware simulation",
+		Status:    summary.StatusFailed,
+		Verdict:   string(r.Verdict),
+		Detail:    r.StatusText,
+		IP:        r.IP,
+	}
+	switch {
+	case r.Verdict == ransomware.VerdictError:
+		entry.Status = summary.StatusError
+	case r.IsEDRActive:
+		entry.Status = summary.StatusEffective
+	}
+	return entry
+}
@@ -0,0 +1,79 @@
+package check
+
+import (
+	"fmt"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/store"
+)
+
+// Registry holds the checks the agent can run, in registration order
+type Registry struct {
+	checks []Check
+}
+
+// NewRegistry returns a registry with the given checks
+func NewRegistry(checks ...Check) *Registry {
+	r := &Registry{}
+	for _, c := range checks {
+		r.Register(c)
+	}
+	return r
+}
+
+// Builtin returns a registry with the antivirus, EDR, DLP and ransomware checks
+func Builtin(cfg *config.Config, env Env) *Registry {
+	return NewRegistry(
+		NewAntivirus(cfg, env),
+		NewEDR(cfg, env),
+		NewDLP(cfg, env),
+		NewRansomware(cfg, env),
+	)
+}
+
+// Register adds a check. It panics if a check with the same ID is already registered.
+func (r *Registry) Register(c Check) {
+	if _, ok := r.Get(c.ID()); ok {
+		panic(fmt.Sprintf("check: %q registered twice", c.ID()))
+	}
+	r.checks = append(r.checks, c)
+}
+
+// Get returns the check with the given ID
+func (r *Registry) Get(id string) (Check, bool) {
+	for _, c := range r.checks {
+		if c.ID() == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Checks returns every registered check
+func (r *Registry) Checks() []Check {
+	return append([]Check(nil), r.checks...)
+}
+
+// IDs returns the IDs of every registered check
+func (r *Registry) IDs() []string {
+	ids := make([]string, 0, len(r.checks))
+	for _, c := range r.checks {
+		ids = append(ids, c.ID())
+	}
+	return ids
+}
+
+// ImportResultsFiles adds the entries of the results file of every check to the result database.
+// Each file is only imported the first time, so the history kept before the database is not lost.
+// It returns the number of entries imported.
+func (r *Registry) ImportResultsFiles(st *store.Store) (int, error) {
+	total := 0
+	for _, c := range r.checks {
+		n, err := st.Import("json:"+c.ID(), c.Records)
+		if err != nil {
+			return total, fmt.Errorf("failed to import %s: %w", c.Config().JSON, err)
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,45 @@
+package check
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dlpagent/internal/config"
+	"dlpagent/internal/store"
+)
+
+func TestImportResultsFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.Antivirus.JSON = filepath.Join(dir, "antivirus_results.json")
+	cfg.Ransomware.JSON = filepath.Join(dir, "ransomware_results.json")
+	cfg.DLP.JSON = filepath.Join(dir, "dlp_results.json")
+	// Two results with the same content, as a check run twice in the same second stores them
+	results := `{"results": [
+		{"timestamp": "2025-12-10T16:56:39Z", "status_text": "Request succeeded: 200 OK", "file_name": "test_credit_card.txt", "category": "credit_card"},
+		{"timestamp": "2025-12-10T16:56:39Z", "status_text": "Request succeeded: 200 OK", "file_name": "test_credit_card.txt", "category": "credit_card"}
+	]}`
+	if err := os.WriteFile(cfg.DLP.JSON, []byte(results), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.New(filepath.Join(dir, "results.db"), store.Options{})
+	if n, err := Builtin(cfg, Env{}).ImportResultsFiles(st); err != nil || n != 2 {
+		t.Fatalf("ImportResultsFiles() = %d, %v, want 2", n, err)
+	}
+	records, err := st.Pending("dlp", 0, 0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Pending() = %d records, %v, want 2", len(records), err)
+	}
+	for _, r := range records {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(r.Data, &entry); err != nil || entry["file_name"] != "test_credit_card.txt" {
+			t.Errorf("record %d data = %s, want the entry of the results file", r.ID, r.Data)
+		}
+	}
+	if records[0].EntryID() == records[1].EntryID() {
+		t.Error("imported records share an entry ID")
+	}
+}
@@ -0,0 +1,410 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"dlpagent/internal/dashboard"
+	"dlpagent/internal/enroll"
+	"dlpagent/internal/proxy"
+	"dlpagent/internal/redact"
+	"dlpagent/internal/scheduler"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/signing"
+	"dlpagent/internal/store"
+)
+
+// Config is the effective agent configuration, layered from defaults,
+// a YAML/TOML file, DLPAGENT_* environment variables and command-line flags
+type Config struct {
+	Server     ServerConfig
+	Agent      AgentConfig
+	Settings   SettingsConfig
+	Schedule   ScheduleConfig
+	Antivirus  AntivirusConfig
+	EDR        EDRConfig
+	DLP        DLPConfig
+	Ransomware RansomwareConfig
+	Run        RunConfig
+	Timeouts   TimeoutConfig
+	Proxy      ProxyConfig
+	Test       TestConfig
+	Store      StoreConfig
+	Encryption EncryptionConfig
+	Upload     UploadConfig
+	Signing    SigningConfig
+
+	// File is the configuration file that was loaded, if any
+	File string
+
+	options []*option
+}
+
+// ServerConfig describes how the agent reaches the dashboard server
+type ServerConfig struct {
+	URL string
+	TLS TLSConfig
+}
+
+// AgentConfig locates the identity the agent received at enrollment
+type AgentConfig struct {
+	IdentityFile string
+	EnrollToken  string // one-time token to enroll with when the agent has no identity yet
+}
+
+// TLSConfig holds the TLS options for dashboard communication
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	Pins               []string      // SPKI pins of the server certificate chain
+	RenewBefore        time.Duration // renew the enrollment certificate this long before it expires
+}
+
+type SettingsConfig struct {
+	CacheFile    string
+	PollInterval time.Duration
+	PushListen   string
+}
+
+// ScheduleConfig applies to the schedules of all checks
+type ScheduleConfig struct {
+	Jitter   time.Duration
+	Blackout []string
+	CatchUp  bool
+}
+
+// Windows parses the blackout windows
+func (s ScheduleConfig) Windows() ([]scheduler.Window, error) {
+	windows := make([]scheduler.Window, 0, len(s.Blackout))
+	for _, spec := range s.Blackout {
+		w, err := scheduler.ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// SchedulerOptions returns the scheduler options for the configured jitter, blackout windows and catch-up
+func (s ScheduleConfig) SchedulerOptions() (scheduler.Options, error) {
+	windows, err := s.Windows()
+	if err != nil {
+		return scheduler.Options{}, err
+	}
+	return scheduler.Options{Jitter: s.Jitter, Blackout: windows, CatchUp: s.CatchUp}, nil
+}
+
+// RunConfig selects a single run instead of the scheduled service
+type RunConfig struct {
+	Once   bool
+	FailOn []string
+}
+
+// TimeoutConfig limits how long network requests and check runs may take. Zero means no limit.
+type TimeoutConfig struct {
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	Request        time.Duration // overall deadline of a single request, including the body
+	Check          time.Duration // overall deadline of a single check run
+}
+
+// TestConfig holds the options of the test traffic (DLP requests and antivirus downloads)
+type TestConfig struct {
+	TLS TestTLSConfig
+}
+
+// TestTLSConfig holds the TLS options of the test traffic, separate from the server's
+type TestTLSConfig struct {
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// ProxyConfig selects the proxy for test traffic
+type ProxyConfig struct {
+	URL      string
+	PAC      string
+	Auth     string
+	Username string
+	Password string
+	Compare  bool // run the HTTP tests both through the proxy and directly
+}
+
+// Options returns the options of the proxy transport
+func (p ProxyConfig) Options() proxy.Options {
+	return proxy.Options{URL: p.URL, PAC: p.PAC, Auth: p.Auth, Username: p.Username, Password: p.Password}
+}
+
+// StoreConfig locates the result database and sets its retention. Zero keeps everything.
+type StoreConfig struct {
+	Path        string
+	MaxAge      time.Duration
+	MaxCount    int
+	FileContent string // how test file contents are kept in stored and uploaded results
+}
+
+// Options returns the retention options of the result database
+func (s StoreConfig) Options() store.Options {
+	return store.Options{MaxAge: s.MaxAge, MaxCount: s.MaxCount}
+}
+
+// UploadConfig controls how results are sent to the dashboard and how long they wait for it
+type UploadConfig struct {
+	BatchSize    int
+	Compress     bool
+	QueueMaxSize int
+	QueueMaxAge  time.Duration
+	RetryMin     time.Duration
+	RetryMax     time.Duration
+}
+
+// QueueOptions returns the limits and retry delays of the upload queue
+func (u UploadConfig) QueueOptions() dashboard.QueueOptions {
+	return dashboard.QueueOptions{MaxSize: u.QueueMaxSize, MaxAge: u.QueueMaxAge, MinBackoff: u.RetryMin, MaxBackoff: u.RetryMax}
+}
+
+// EncryptionConfig selects the key the stored results and the settings cache are sealed with
+type EncryptionConfig struct {
+	KeySource string // none, file or keyring
+	KeyFile   string
+}
+
+// StorageKey loads the key stored results and the settings cache are encrypted with, creating
+// its secret on first use. It returns nil when encryption is disabled.
+func (c *Config) StorageKey() (*seal.Key, error) {
+	if c.Encryption.KeySource == seal.SourceEnrollment {
+		id, err := c.Identity()
+		if err != nil {
+			return nil, fmt.Errorf("encryption: %w", err)
+		}
+		if id == nil {
+			return nil, errors.New("encryption: the key is derived from the enrollment credentials, but the agent is not enrolled")
+		}
+		return seal.NewKey(id.StorageSecret())
+	}
+
+	key, err := seal.Load(c.Encryption.KeySource, c.Encryption.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	return key, nil
+}
+
+// SigningConfig pins the keys the server signs its settings and test catalogs with
+type SigningConfig struct {
+	PublicKeys []string // Base64 Ed25519 public keys, more than one while the server key is rotated
+	Strict     bool     // refuse unsigned documents
+	StateFile  string   // highest accepted document versions, to reject replays
+}
+
+// Verifier returns the verifier of the server's documents
+func (s SigningConfig) Verifier() (*signing.Verifier, error) {
+	return signing.NewVerifier(s.PublicKeys, s.Strict, s.StateFile)
+}
+
+// Identity returns the identity the agent received at enrollment, or nil if it is not enrolled
+func (c *Config) Identity() (*enroll.Identity, error) {
+	return enroll.Load(c.Agent.IdentityFile)
+}
+
+// CheckConfig holds the keys every check has: <check>.enabled, .interval, .schedule and .json
+type CheckConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Schedule string
+	JSON     string
+}
+
+type AntivirusConfig struct {
+	CheckConfig
+}
+
+type EDRConfig struct {
+	CheckConfig
+	Techniques []string
+}
+
+type DLPConfig struct {
+	CheckConfig
+	URL         string
+	Method      string
+	Files       []string
+	SamplesDir  string // directory the test files named by the server may be taken from
+	Concurrency int
+	RateLimit   float64 // requests per second to each target host, 0 for no limit
+}
+
+type RansomwareConfig struct {
+	CheckConfig
+	Dir string
+}
+
+// Defaults returns the configuration used when nothing else is set
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			URL: "http://127.0.0.1:8000",
+			TLS: TLSConfig{RenewBefore: 30 * 24 * time.Hour},
+		},
+		Agent: AgentConfig{
+			IdentityFile: "dlpagent-identity.json",
+		},
+		Settings: SettingsConfig{
+			CacheFile:    "settings_cache.json",
+			PollInterval: time.Minute,
+		},
+		Schedule: ScheduleConfig{
+			CatchUp: true,
+		},
+		Antivirus: AntivirusConfig{
+			CheckConfig: CheckConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				JSON:     "antivirus_results.json",
+			},
+		},
+		EDR: EDRConfig{
+			CheckConfig: CheckConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				JSON:     "edr_results.json",
+			},
+		},
+		DLP: DLPConfig{
+			CheckConfig: CheckConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				JSON:     "dlp_results.json",
+			},
+			Method:      "GET",
+			Concurrency: 4,
+		},
+		Ransomware: RansomwareConfig{
+			CheckConfig: CheckConfig{
+				Enabled:  true,
+				Interval: time.Hour,
+				JSON:     "ransomware_results.json",
+			},
+		},
+		Timeouts: TimeoutConfig{
+			Connect:        10 * time.Second,
+			TLSHandshake:   10 * time.Second,
+			ResponseHeader: 30 * time.Second,
+			Request:        time.Minute,
+			Check:          15 * time.Minute,
+		},
+		Store: StoreConfig{
+			Path:        "dlpagent.db",
+			MaxAge:      90 * 24 * time.Hour,
+			MaxCount:    100000,
+			FileContent: redact.Masked,
+		},
+		Encryption: EncryptionConfig{
+			KeySource: seal.SourceNone,
+			KeyFile:   "dlpagent.key",
+		},
+		Upload: UploadConfig{
+			BatchSize:    dashboard.DefaultBatchSize,
+			Compress:     true,
+			QueueMaxSize: 10000,
+			QueueMaxAge:  7 * 24 * time.Hour,
+			RetryMin:     30 * time.Second,
+			RetryMax:     10 * time.Minute,
+		},
+		Signing: SigningConfig{
+			StateFile: "signing_state.json",
+		},
+	}
+}
+
+// bind registers every configuration key with its usage text and target field
+func (c *Config) bind() {
+	c.options = []*option{
+		{key: "server.url", usage: "Base URL of the dashboard server", value: (*stringValue)(&c.Server.URL)},
+		{key: "agent.identity_file", usage: "File the agent identity received at enrollment is kept in", value: (*stringValue)(&c.Agent.IdentityFile)},
+		{key: "agent.enroll_token", usage: "One-time token to enroll with on first start (see dlpagent enroll)", value: (*secretValue)(&c.Agent.EnrollToken)},
+		{key: "server.tls.ca_file", usage: "CA bundle used to verify the dashboard server", value: (*stringValue)(&c.Server.TLS.CAFile)},
+		{key: "server.tls.cert_file", usage: "Client certificate for the dashboard server", value: (*stringValue)(&c.Server.TLS.CertFile)},
+		{key: "server.tls.key_file", usage: "Client certificate key for the dashboard server", value: (*stringValue)(&c.Server.TLS.KeyFile)},
+		{key: "server.tls.insecure_skip_verify", usage: "Skip verification of the dashboard server certificate", value: (*boolValue)(&c.Server.TLS.InsecureSkipVerify)},
+		{key: "server.tls.pins", usage: "SPKI pins (sha256/<base64>) of which the dashboard certificate chain must contain one", value: (*listValue)(&c.Server.TLS.Pins)},
+		{key: "server.tls.renew_before", usage: "Renew the client certificate issued at enrollment this long before it expires (0 never renews)", value: (*durationValue)(&c.Server.TLS.RenewBefore)},
+		{key: "settings.cache_file", usage: "File storing the last known good server settings", value: (*stringValue)(&c.Settings.CacheFile)},
+		{key: "settings.poll_interval", usage: "Interval between checks for changed server settings", value: (*durationValue)(&c.Settings.PollInterval)},
+		{key: "settings.push_listen", usage: "Address to accept settings change notifications on, e.g. 127.0.0.1:8765 (default: disabled)", value: (*stringValue)(&c.Settings.PushListen)},
+		{key: "schedule.jitter", usage: "Delay every check by a random duration up to this value", value: (*durationValue)(&c.Schedule.Jitter)},
+		{key: "schedule.blackout", usage: "Semicolon-separated windows in which no check starts, e.g. \"Mon-Fri 09:00-17:00\"", value: (*windowListValue)(&c.Schedule.Blackout)},
+		{key: "schedule.catch_up", usage: "Run a check once when its run was missed while the machine was asleep", value: (*boolValue)(&c.Schedule.CatchUp)},
+		{key: "antivirus.enabled", usage: "Run the antivirus check", value: (*boolValue)(&c.Antivirus.Enabled)},
+		{key: "antivirus.interval", usage: "Interval between antivirus checks", value: (*durationValue)(&c.Antivirus.Interval)},
+		{key: "antivirus.schedule", usage: "Cron expression for antivirus checks, overrides antivirus.interval", value: (*stringValue)(&c.Antivirus.Schedule)},
+		{key: "antivirus.json", usage: "Path to JSON file to store antivirus results", value: (*stringValue)(&c.Antivirus.JSON)},
+		{key: "edr.enabled", usage: "Run the EDR behaviour simulations check", value: (*boolValue)(&c.EDR.Enabled)},
+		{key: "edr.interval", usage: "Interval between EDR behaviour simulation checks", value: (*durationValue)(&c.EDR.Interval)},
+		{key: "edr.schedule", usage: "Cron expression for EDR behaviour simulation checks, overrides edr.interval", value: (*stringValue)(&c.EDR.Schedule)},
+		{key: "edr.json", usage: "Path to JSON file to store EDR behaviour simulation results", value: (*stringValue)(&c.EDR.JSON)},
+		{key: "edr.techniques", usage: "MITRE ATT&CK technique IDs of the EDR simulations to run (default: all)", value: (*listValue)(&c.EDR.Techniques)},
+		{key: "dlp.enabled", usage: "Run the DLP check", value: (*boolValue)(&c.DLP.Enabled)},
+		{key: "dlp.interval", usage: "Interval between DLP checks", value: (*durationValue)(&c.DLP.Interval)},
+		{key: "dlp.schedule", usage: "Cron expression for DLP checks, overrides dlp.interval", value: (*stringValue)(&c.DLP.Schedule)},
+		{key: "dlp.json", usage: "Path to JSON file to store DLP results", value: (*stringValue)(&c.DLP.JSON)},
+		{key: "dlp.url", usage: "Target URL for the DLP check (default: from server settings)", value: (*stringValue)(&c.DLP.URL)},
+		{key: "dlp.method", usage: "HTTP method for DLP requests", value: (*stringValue)(&c.DLP.Method)},
+		{key: "dlp.files", usage: "Test files for the DLP check (default: generated samples)", value: (*listValue)(&c.DLP.Files)},
+		{key: "dlp.samples_dir", usage: "Directory the DLP test files named by the server may be taken from", value: (*stringValue)(&c.DLP.SamplesDir)},
+		{key: "dlp.concurrency", usage: "Number of DLP requests in flight at once", value: (*intValue)(&c.DLP.Concurrency)},
+		{key: "dlp.rate_limit", usage: "Maximum DLP requests per second to each target host (0 for no limit)", value: (*floatValue)(&c.DLP.RateLimit)},
+		{key: "ransomware.enabled", usage: "Run the ransomware simulation check", value: (*boolValue)(&c.Ransomware.Enabled)},
+		{key: "ransomware.interval", usage: "Interval between ransomware simulation checks", value: (*durationValue)(&c.Ransomware.Interval)},
+		{key: "ransomware.schedule", usage: "Cron expression for ransomware simulation checks, overrides ransomware.interval", value: (*stringValue)(&c.Ransomware.Schedule)},
+		{key: "ransomware.json", usage: "Path to JSON file to store ransomware simulation results", value: (*stringValue)(&c.Ransomware.JSON)},
+		{key: "ransomware.dir", usage: "Directory the ransomware sandbox is created in (default: system temp dir)", value: (*stringValue)(&c.Ransomware.Dir)},
+		{key: "run.once", usage: "Run the enabled checks once, print a summary and exit with 0 (effective), 1 (control failed) or 3 (error)", value: (*boolValue)(&c.Run.Once)},
+		{key: "run.fail_on", usage: "Categories that decide the exit code of a single run: all, antivirus, edr, dlp, ransomware (default: all)", value: (*listValue)(&c.Run.FailOn)},
+		{key: "timeouts.connect", usage: "Maximum time to establish a TCP connection", value: (*durationValue)(&c.Timeouts.Connect)},
+		{key: "timeouts.tls_handshake", usage: "Maximum time for a TLS handshake", value: (*durationValue)(&c.Timeouts.TLSHandshake)},
+		{key: "timeouts.response_header", usage: "Maximum time to wait for response headers after sending a request", value: (*durationValue)(&c.Timeouts.ResponseHeader)},
+		{key: "timeouts.request", usage: "Overall deadline of a single request, including reading the body", value: (*durationValue)(&c.Timeouts.Request)},
+		{key: "timeouts.check", usage: "Overall deadline of a single check run", value: (*durationValue)(&c.Timeouts.Check)},
+		{key: "test.tls.ca_file", usage: "CA bundle trusted for test traffic in addition to the system roots, e.g. of a TLS-inspecting proxy", value: (*stringValue)(&c.Test.TLS.CAFile)},
+		{key: "test.tls.insecure_skip_verify", usage: "Skip verification of certificates in test traffic", value: (*boolValue)(&c.Test.TLS.InsecureSkipVerify)},
+		{key: "proxy.url", usage: "Proxy for test traffic: http://, https:// or socks5:// URL, or \"env\" for HTTP_PROXY, HTTPS_PROXY and NO_PROXY (default: direct)", value: (*stringValue)(&c.Proxy.URL)},
+		{key: "proxy.pac", usage: "PAC file URL or path choosing the proxy per request, or \"wpad\" to discover it; overrides proxy.url", value: (*stringValue)(&c.Proxy.PAC)},
+		{key: "proxy.auth", usage: "Proxy authentication: none, basic, ntlm or negotiate (default: basic with a username, none without)", value: (*stringValue)(&c.Proxy.Auth)},
+		{key: "proxy.username", usage: "Proxy username, DOMAIN\\user for NTLM or user@REALM for Negotiate", value: (*stringValue)(&c.Proxy.Username)},
+		{key: "proxy.password", usage: "Proxy password; Negotiate without a password uses the Kerberos credential cache", value: (*secretValue)(&c.Proxy.Password)},
+		{key: "proxy.compare", usage: "Run the HTTP tests both through the proxy and directly to show whether the proxy can be bypassed", value: (*boolValue)(&c.Proxy.Compare)},
+		{key: "store.path", usage: "Path to the result database", value: (*stringValue)(&c.Store.Path)},
+		{key: "store.max_age", usage: "Remove stored results older than this (0 keeps them)", value: (*durationValue)(&c.Store.MaxAge)},
+		{key: "store.max_count", usage: "Keep at most this many stored results, removing the oldest (0 for no limit)", value: (*intValue)(&c.Store.MaxCount)},
+		{key: "store.file_content", usage: "How test file contents are kept in stored and uploaded results: full, masked, hash or omit", value: (*stringValue)(&c.Store.FileContent)},
+		{key: "upload.batch_size", usage: "Maximum number of results sent to the dashboard in one request", value: (*intValue)(&c.Upload.BatchSize)},
+		{key: "upload.compress", usage: "Compress uploads to the dashboard with gzip", value: (*boolValue)(&c.Upload.Compress)},
+		{key: "upload.queue_max_size", usage: "Maximum number of results per check waiting for the dashboard; the oldest are dropped (0 for no limit)", value: (*intValue)(&c.Upload.QueueMaxSize)},
+		{key: "upload.queue_max_age", usage: "Drop results that have waited longer than this for the dashboard (0 keeps them)", value: (*durationValue)(&c.Upload.QueueMaxAge)},
+		{key: "upload.retry_min", usage: "Delay before retrying a failed upload, doubled after every further failure", value: (*durationValue)(&c.Upload.RetryMin)},
+		{key: "upload.retry_max", usage: "Maximum delay between upload retries", value: (*durationValue)(&c.Upload.RetryMax)},
+		{key: "signing.public_keys", usage: "Base64 Ed25519 public keys the server settings and test catalogs must be signed with (default: not verified)", value: (*listValue)(&c.Signing.PublicKeys)},
+		{key: "signing.strict", usage: "Refuse unsigned settings and test catalogs; requires signing.public_keys", value: (*boolValue)(&c.Signing.Strict)},
+		{key: "signing.state_file", usage: "File keeping the versions of the last accepted signed documents, to reject replays", value: (*stringValue)(&c.Signing.StateFile)},
+		{key: "encryption.key_source", usage: "Key that stored results and cached settings are encrypted with: none, file, keyring or enrollment", value: (*stringValue)(&c.Encryption.KeySource)},
+		{key: "encryption.key_file", usage: "Key file used with encryption.key_source file; created on first use", value: (*stringValue)(&c.Encryption.KeyFile)},
+	}
+	for _, opt := range c.options {
+		opt.source = Source{Kind: SourceDefault}
+	}
+}
+
+// lookup returns the option registered for key
+func (c *Config) lookup(key string) *option {
+	for _, opt := range c.options {
+		if opt.key == key {
+			return opt
+		}
+	}
+	return nil
+}
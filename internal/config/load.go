@@ -0,0 +1,404 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dlpagent/internal/redact"
+	"dlpagent/internal/scheduler"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/signing"
+	"dlpagent/internal/summary"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of every configuration environment variable
+const EnvPrefix = "DLPAGENT_"
+
+// EnvConfigFile names the configuration file when -config is not given
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+// defaultFiles are looked up in the working directory when no file is named
+var defaultFiles = []string{"dlpagent.yaml", "dlpagent.yml", "dlpagent.toml"}
+
+// Alias is an additional, binary-specific flag for a configuration key,
+// e.g. -json for antivirus.json or -skip-dlp for dlp.enabled (negated)
+type Alias struct {
+	Flag   string
+	Key    string
+	Usage  string
+	Negate bool
+}
+
+// Load builds the configuration from defaults, the configuration file, DLPAGENT_*
+// environment variables and the command-line arguments, in that order, and validates it.
+// flag.ErrHelp is returned when -h or -help was given.
+func Load(name string, args []string, aliases ...Alias) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	c, rest, err := Parse(fs, args, aliases...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	return c, nil
+}
+
+// Parse is like Load but registers the configuration flags on fs, which may carry
+// additional flags and its own usage, and returns the remaining positional arguments.
+func Parse(fs *flag.FlagSet, args []string, aliases ...Alias) (*Config, []string, error) {
+	c := Defaults()
+	c.bind()
+
+	fs.String("config", "", "Path to YAML or TOML configuration file (env "+EnvConfigFile+")")
+	for _, opt := range c.options {
+		fs.Var(&flagValue{opt: opt, name: opt.key}, opt.key, opt.usage)
+	}
+	for _, alias := range aliases {
+		opt := c.lookup(alias.Key)
+		if opt == nil {
+			return nil, nil, fmt.Errorf("flag -%s refers to unknown key %s", alias.Flag, alias.Key)
+		}
+		usage := alias.Usage
+		if usage == "" {
+			usage = opt.usage
+		}
+		fs.Var(&flagValue{opt: opt, name: alias.Flag, negate: alias.Negate}, alias.Flag, usage)
+	}
+
+	if err := c.loadFile(configFileArg(args)); err != nil {
+		return nil, nil, err
+	}
+	if err := c.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// Positional arguments may be followed by more flags
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, rest, nil
+}
+
+// configFileArg finds the configuration file from -config, DLPAGENT_CONFIG or the default names
+func configFileArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == "config" && i+1 < len(args) && arg != name {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") && arg != name {
+			return strings.TrimPrefix(name, "config=")
+		}
+	}
+
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		return path
+	}
+
+	for _, path := range defaultFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadFile applies a YAML or TOML configuration file
+func (c *Config) loadFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]interface{}{}
+	flatten("", raw, values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	source := Source{Kind: SourceFile, Name: path}
+	for _, key := range keys {
+		opt := c.lookup(key)
+		if opt == nil {
+			return fmt.Errorf("unknown key %s in config file %s", key, path)
+		}
+
+		if items, ok := values[key].([]interface{}); ok {
+			parts := make([]string, 0, len(items))
+			for _, item := range items {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			if err := opt.setList(parts, source); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := opt.set(fmt.Sprint(values[key]), source); err != nil {
+			return err
+		}
+	}
+
+	c.File = path
+	return nil
+}
+
+// flatten turns nested maps into dotted keys
+func flatten(prefix string, in map[string]interface{}, out map[string]interface{}) {
+	for key, value := range in {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+// loadEnv applies DLPAGENT_* environment variables
+func (c *Config) loadEnv() error {
+	for _, opt := range c.options {
+		name := opt.envName()
+		if value, ok := os.LookupEnv(name); ok {
+			if err := opt.set(value, Source{Kind: SourceEnv, Name: name}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks the effective configuration and reports every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(c.Server.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("server.url must be an http(s) URL, got %q", c.Server.URL)
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		add("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+	if _, err := parsePins(c.Server.TLS.Pins); err != nil {
+		add("server.tls.pins: %v", err)
+	}
+	if u, err := url.Parse(c.Server.URL); err == nil && u.Scheme == "http" {
+		if len(c.Server.TLS.Pins) > 0 || c.Server.TLS.CertFile != "" || c.Server.TLS.CAFile != "" {
+			add("server.tls options require an https:// server.url, got %q", c.Server.URL)
+		}
+	}
+	for key, path := range map[string]string{
+		"server.tls.ca_file":   c.Server.TLS.CAFile,
+		"server.tls.cert_file": c.Server.TLS.CertFile,
+		"server.tls.key_file":  c.Server.TLS.KeyFile,
+		"test.tls.ca_file":     c.Test.TLS.CAFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			add("%s: %v", key, err)
+		}
+	}
+
+	for key, expr := range map[string]string{
+		"antivirus.schedule":  c.Antivirus.Schedule,
+		"edr.schedule":        c.EDR.Schedule,
+		"dlp.schedule":        c.DLP.Schedule,
+		"ransomware.schedule": c.Ransomware.Schedule,
+	} {
+		if expr == "" {
+			continue
+		}
+		if _, err := scheduler.ParseCron(expr); err != nil {
+			add("%s: %v", key, err)
+		}
+	}
+	if _, err := c.Schedule.Windows(); err != nil {
+		add("schedule.blackout: %v", err)
+	}
+	if _, err := summary.ParsePolicy(c.Run.FailOn); err != nil {
+		add("run.fail_on: %v", err)
+	}
+	for key, d := range map[string]time.Duration{
+		"schedule.jitter":          c.Schedule.Jitter,
+		"timeouts.connect":         c.Timeouts.Connect,
+		"timeouts.tls_handshake":   c.Timeouts.TLSHandshake,
+		"timeouts.response_header": c.Timeouts.ResponseHeader,
+		"timeouts.request":         c.Timeouts.Request,
+		"timeouts.check":           c.Timeouts.Check,
+		"store.max_age":            c.Store.MaxAge,
+		"server.tls.renew_before":  c.Server.TLS.RenewBefore,
+		"upload.queue_max_age":     c.Upload.QueueMaxAge,
+	} {
+		if d < 0 {
+			add("%s must not be negative", key)
+		}
+	}
+
+	for key, interval := range map[string]int64{
+		"antivirus.interval":     int64(c.Antivirus.Interval),
+		"edr.interval":           int64(c.EDR.Interval),
+		"dlp.interval":           int64(c.DLP.Interval),
+		"ransomware.interval":    int64(c.Ransomware.Interval),
+		"settings.poll_interval": int64(c.Settings.PollInterval),
+	} {
+		if interval <= 0 {
+			add("%s must be positive", key)
+		}
+	}
+
+	if c.Settings.PushListen != "" {
+		if _, _, err := net.SplitHostPort(c.Settings.PushListen); err != nil {
+			add("settings.push_listen must be host:port, got %q", c.Settings.PushListen)
+		}
+	}
+
+	if c.DLP.URL != "" {
+		if u, err := url.Parse(c.DLP.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("dlp.url must be an absolute URL, got %q", c.DLP.URL)
+		}
+	}
+	switch strings.ToUpper(c.DLP.Method) {
+	case "GET", "POST", "PUT", "PATCH":
+		c.DLP.Method = strings.ToUpper(c.DLP.Method)
+	default:
+		add("dlp.method must be GET, POST, PUT or PATCH, got %q", c.DLP.Method)
+	}
+	if c.DLP.Concurrency < 1 {
+		add("dlp.concurrency must be at least 1, got %d", c.DLP.Concurrency)
+	}
+	if c.DLP.RateLimit < 0 {
+		add("dlp.rate_limit must not be negative")
+	}
+	if c.Store.MaxCount < 0 {
+		add("store.max_count must not be negative")
+	}
+	if err := redact.Validate(c.Store.FileContent); err != nil {
+		add("store.file_content: %v", err)
+	}
+	if c.Upload.BatchSize < 1 {
+		add("upload.batch_size must be at least 1, got %d", c.Upload.BatchSize)
+	}
+	if c.Upload.QueueMaxSize < 0 {
+		add("upload.queue_max_size must not be negative")
+	}
+	if c.Upload.RetryMin <= 0 {
+		add("upload.retry_min must be positive")
+	} else if c.Upload.RetryMax < c.Upload.RetryMin {
+		add("upload.retry_max must not be less than upload.retry_min")
+	}
+	switch c.Encryption.KeySource {
+	case seal.SourceNone, seal.SourceKeyring, seal.SourceEnrollment:
+	case seal.SourceFile:
+		if c.Encryption.KeyFile == "" {
+			add("encryption.key_file is required with encryption.key_source file")
+		}
+	default:
+		add("encryption.key_source must be none, file, keyring or enrollment, got %q", c.Encryption.KeySource)
+	}
+	for _, key := range c.Signing.PublicKeys {
+		if _, err := signing.ParseKey(key); err != nil {
+			add("signing.public_keys: %v", err)
+		}
+	}
+	if c.Signing.Strict && len(c.Signing.PublicKeys) == 0 {
+		add("signing.strict requires signing.public_keys")
+	}
+	if c.Proxy.Options().Enabled() {
+		if err := c.Proxy.Options().Validate(); err != nil {
+			add("proxy: %v", err)
+		}
+	} else if c.Proxy.Compare {
+		add("proxy.compare requires proxy.url or proxy.pac")
+	}
+	for _, file := range c.DLP.Files {
+		if _, err := os.Stat(file); err != nil {
+			add("dlp.files: %v", err)
+		}
+	}
+
+	for key, path := range map[string]string{
+		"antivirus.json":      c.Antivirus.JSON,
+		"edr.json":            c.EDR.JSON,
+		"dlp.json":            c.DLP.JSON,
+		"ransomware.json":     c.Ransomware.JSON,
+		"store.path":          c.Store.Path,
+		"agent.identity_file": c.Agent.IdentityFile,
+		"signing.state_file":  c.Signing.StateFile,
+	} {
+		if path == "" {
+			add("%s must not be empty", key)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+// Print writes every effective value and where it came from
+func (c *Config) Print(w io.Writer) {
+	if c.File != "" {
+		fmt.Fprintf(w, "# config file: %s\n", c.File)
+	}
+	for _, opt := range c.options {
+		value := opt.value.String()
+		if value == "" {
+			value = `""`
+		}
+		fmt.Fprintf(w, "%-34s = %-40s # %s\n", opt.key, value, opt.source)
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceKind tells which configuration layer set a value
+type SourceKind string
+
+const (
+	SourceDefault SourceKind = "default"
+	SourceFile    SourceKind = "file"
+	SourceEnv     SourceKind = "env"
+	SourceFlag    SourceKind = "flag"
+)
+
+// Source records where an effective value came from
+type Source struct {
+	Kind SourceKind
+	Name string // file path, environment variable or flag name
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return fmt.Sprintf("%s %s", s.Kind, s.Name)
+}
+
+// option is a single configuration key bound to a field of Config
+type option struct {
+	key    string
+	usage  string
+	value  flag.Value
+	source Source
+}
+
+// envName returns the environment variable for the option, e.g. DLPAGENT_SERVER_TLS_CA_FILE
+func (o *option) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(o.key, ".", "_"))
+}
+
+func (o *option) set(value string, source Source) error {
+	return o.setList([]string{value}, source)
+}
+
+// setList replaces the value with the given items; non-list values take the last item
+func (o *option) setList(values []string, source Source) error {
+	if list, ok := o.value.(resetter); ok {
+		list.reset()
+	}
+	for _, value := range values {
+		if err := o.value.Set(value); err != nil {
+			return fmt.Errorf("invalid value %q for %s (%s): %w", value, o.key, source, err)
+		}
+	}
+	o.source = source
+	return nil
+}
+
+// resetter is implemented by list values, which a higher layer replaces instead of appending to
+type resetter interface {
+	reset()
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+// secretValue is a string that is masked when printed
+type secretValue string
+
+func (v *secretValue) Set(s string) error {
+	*v = secretValue(s)
+	return nil
+}
+
+func (v *secretValue) String() string {
+	if *v == "" {
+		return ""
+	}
+	return "********"
+}
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+// listValue holds a comma-separated list; repeated flags append to it
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+func (v *listValue) reset() { *v = nil }
+
+// windowListValue holds a semicolon-separated list of time windows, which may contain commas
+type windowListValue []string
+
+func (v *windowListValue) Set(s string) error {
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+func (v *windowListValue) String() string { return strings.Join(*v, "; ") }
+
+func (v *windowListValue) reset() { *v = nil }
+
+// flagValue sets an option from the command line and records the flag as its source.
+// The first occurrence of a list flag replaces values from lower layers, later ones append.
+type flagValue struct {
+	opt    *option
+	name   string
+	negate bool
+}
+
+func (f *flagValue) Set(s string) error {
+	if f.negate {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		s = strconv.FormatBool(!b)
+	}
+
+	if list, ok := f.opt.value.(resetter); ok && f.opt.source.Kind != SourceFlag {
+		list.reset()
+	}
+	if err := f.opt.value.Set(s); err != nil {
+		return err
+	}
+	f.opt.source = Source{Kind: SourceFlag, Name: "-" + f.name}
+	return nil
+}
+
+func (f *flagValue) String() string {
+	if f.opt == nil {
+		return ""
+	}
+	return f.opt.value.String()
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	b, ok := f.opt.value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"dlpagent/internal/proxy"
+)
+
+// ServerTLSConfig builds the TLS configuration for dashboard communication. The client
+// certificate is loaded for every handshake, so a renewed certificate is used without a restart.
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.Server.TLS.InsecureSkipVerify,
+	}
+
+	if c.Server.TLS.CAFile != "" {
+		pem, err := os.ReadFile(c.Server.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.Server.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.Server.TLS.Pins) > 0 {
+		pins, err := parsePins(c.Server.TLS.Pins)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+
+	// Fail now rather than at the first handshake
+	if _, err := c.clientCertificate(); err != nil {
+		return nil, err
+	}
+	tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cert, err := c.clientCertificate()
+		if cert == nil {
+			// Sending no certificate lets the server decide
+			cert = &tls.Certificate{}
+		}
+		return cert, err
+	}
+
+	return tlsConfig, nil
+}
+
+// clientCertificate loads server.tls.cert_file, or the certificate issued at enrollment.
+// It returns nil when there is neither.
+func (c *Config) clientCertificate() (*tls.Certificate, error) {
+	if c.Server.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.Server.TLS.CertFile, c.Server.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+	id, err := c.Identity()
+	if err != nil || id == nil {
+		return nil, err
+	}
+	return id.TLSCertificate()
+}
+
+// parsePins decodes SPKI pins, the Base64 SHA-256 of a certificate's public key, optionally
+// prefixed with "sha256/"
+func parsePins(values []string) ([][]byte, error) {
+	var pins [][]byte
+	for _, value := range values {
+		pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "sha256/"))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q: expected the Base64 SHA-256 of a public key", value)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// verifyPins accepts a connection when a certificate of a verified chain has a pinned public key.
+// Only verified chains count: the server may send any certificate along with its own, including
+// the pinned one. Without verification (server.tls.insecure_skip_verify) only the server's own
+// certificate is checked.
+func verifyPins(cs tls.ConnectionState, pins [][]byte) error {
+	var certs []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+	}
+	return errors.New("the server certificate matches no pinned public key (server.tls.pins)")
+}
+
+// ServerHTTPClient returns an HTTP client for the settings fetch and result upload. Once the
+// agent is enrolled, its requests are authenticated with the agent identity.
+func (c *Config) ServerHTTPClient() (*http.Client, error) {
+	client, err := c.EnrollmentHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.Identity()
+	if err != nil {
+		return nil, err
+	}
+	if id != nil {
+		client.Transport = id.Transport(client.Transport)
+	}
+	return client, nil
+}
+
+// EnrollmentHTTPClient returns an HTTP client for the server without the agent credentials
+func (c *Config) EnrollmentHTTPClient() (*http.Client, error) {
+	tlsConfig, err := c.ServerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := c.transport()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeouts.Request,
+	}, nil
+}
+
+// TestTLSConfig builds the TLS configuration of the test traffic. It is independent of the
+// server's: test.tls.ca_file adds to the system roots, e.g. the CA of a TLS-inspecting proxy.
+func (c *Config) TestTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Test.TLS.InsecureSkipVerify}
+	if c.Test.TLS.CAFile != "" {
+		pem, err := os.ReadFile(c.Test.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.Test.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// TestHTTPClient returns an HTTP client for the test traffic of the checks, through the
+// configured proxy if any
+func (c *Config) TestHTTPClient() (*http.Client, error) {
+	if !c.Proxy.Options().Enabled() {
+		return c.DirectHTTPClient()
+	}
+
+	base, err := c.testTransport()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := proxy.New(base, c.dialer(), c.Proxy.Options())
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeouts.Request,
+	}, nil
+}
+
+// DirectHTTPClient returns an HTTP client for test traffic that bypasses every proxy
+func (c *Config) DirectHTTPClient() (*http.Client, error) {
+	transport, err := c.testTransport()
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeouts.Request,
+	}, nil
+}
+
+// testTransport returns the transport of the test traffic with its own TLS configuration
+func (c *Config) testTransport() (*http.Transport, error) {
+	tlsConfig, err := c.TestTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := c.transport()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// transport returns an HTTP transport with the configured connect, TLS handshake and response header timeouts
+func (c *Config) transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = c.dialer().DialContext
+	transport.TLSHandshakeTimeout = c.Timeouts.TLSHandshake
+	transport.ResponseHeaderTimeout = c.Timeouts.ResponseHeader
+	return transport
+}
+
+func (c *Config) dialer() *net.Dialer {
+	return &net.Dialer{Timeout: c.Timeouts.Connect, KeepAlive: 30 * time.Second}
+}
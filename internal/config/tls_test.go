@@ -0,0 +1,135 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newCert returns a certificate for a new key, signed by parent or self-signed if parent is nil
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func pinOf(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyPins(t *testing.T) {
+	root, rootKey := newCert(t, "root", nil, nil)
+	leaf, _ := newCert(t, "dashboard", root, rootKey)
+	// The real dashboard certificate, which an attacker can send along with their own
+	real, _ := newCert(t, "real dashboard", nil, nil)
+
+	tests := []struct {
+		name  string
+		pin   *x509.Certificate
+		state tls.ConnectionState
+		ok    bool
+	}{
+		{
+			name:  "pinned root in verified chain",
+			pin:   root,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: [][]*x509.Certificate{{leaf, root}}},
+			ok:    true,
+		},
+		{
+			name:  "pinned leaf in verified chain",
+			pin:   leaf,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: [][]*x509.Certificate{{leaf, root}}},
+			ok:    true,
+		},
+		{
+			name:  "pinned certificate only sent, not verified",
+			pin:   real,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, real}, VerifiedChains: [][]*x509.Certificate{{leaf, root}}},
+		},
+		{
+			name:  "no pinned certificate",
+			pin:   real,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: [][]*x509.Certificate{{leaf, root}}},
+		},
+		{
+			name:  "unverified, pinned server certificate",
+			pin:   leaf,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, root}},
+			ok:    true,
+		},
+		{
+			name:  "unverified, pinned certificate sent after the server's",
+			pin:   real,
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, real}},
+		},
+		{
+			name:  "unverified, no certificate",
+			pin:   real,
+			state: tls.ConnectionState{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := parsePins([]string{pinOf(tt.pin)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = verifyPins(tt.state, pins)
+			if tt.ok && err != nil {
+				t.Errorf("verifyPins() = %v, want nil", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("verifyPins() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	tests := []struct {
+		pin string
+		ok  bool
+	}{
+		{"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", true},
+		{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", true},
+		{"sha256/not base64", false},
+		{"sha256/AAAA", false},
+	}
+	for _, tt := range tests {
+		_, err := parsePins([]string{tt.pin})
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePins(%q) error = %v, want ok %v", tt.pin, err, tt.ok)
+		}
+	}
+}
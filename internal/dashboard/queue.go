@@ -0,0 +1,262 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"dlpagent/internal/store"
+)
+
+// QueueOptions bound the results waiting for upload and set the delays between retries
+type QueueOptions struct {
+	MaxSize    int           // results per check kept waiting, dropping the oldest; 0 for no limit
+	MaxAge     time.Duration // results waiting longer are dropped; 0 keeps them
+	MinBackoff time.Duration // delay before the first retry, doubled after every failure
+	MaxBackoff time.Duration
+}
+
+// QueueStatus is the state of the upload queue reported with the agent status
+type QueueStatus struct {
+	Pending   int        `json:"pending"`
+	Dropped   int        `json:"dropped"`
+	Failures  int        `json:"failures"`
+	NextRetry *time.Time `json:"next_retry,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Queue forwards stored results to the dashboard. The results waiting for upload are the
+// records of each check after its last acknowledged upload, so the queue survives restarts
+// and needs no copy of the results. After a failed upload the queue backs off exponentially,
+// with jitter, and Run retries until the dashboard is reachable again.
+type Queue struct {
+	uploader *Uploader
+	store    *store.Store
+	options  QueueOptions
+	checks   []queuedCheck
+	wake     chan struct{}
+
+	flushing  sync.Mutex // one flush at a time, so no result is sent twice
+	mu        sync.Mutex
+	dropped   int
+	failures  int
+	nextRetry time.Time
+	lastErr   error
+}
+
+type queuedCheck struct {
+	id       string
+	endpoint string
+}
+
+// NewQueue creates the upload queue of the results in st
+func NewQueue(uploader *Uploader, st *store.Store, opts QueueOptions) *Queue {
+	return &Queue{uploader: uploader, store: st, options: opts, wake: make(chan struct{}, 1)}
+}
+
+// Add registers the results of check to be uploaded to endpoint
+func (q *Queue) Add(check, endpoint string) {
+	q.checks = append(q.checks, queuedCheck{id: check, endpoint: endpoint})
+}
+
+// Flush uploads the waiting results of check, or of every check when check is empty, unless
+// the queue is backing off after a failure. It returns the number of results uploaded.
+func (q *Queue) Flush(ctx context.Context, check string) (int, error) {
+	q.mu.Lock()
+	backingOff := q.failures > 0 && time.Now().Before(q.nextRetry)
+	nextRetry := q.nextRetry
+	q.mu.Unlock()
+	if backingOff {
+		return 0, fmt.Errorf("dashboard unreachable, next retry at %s", nextRetry.Format(time.RFC3339))
+	}
+	return q.flush(ctx, check)
+}
+
+func (q *Queue) flush(ctx context.Context, check string) (int, error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	sent := 0
+	for _, c := range q.checks {
+		if check != "" && c.id != check {
+			continue
+		}
+		if err := q.trim(c.id); err != nil {
+			return sent, err
+		}
+		n, rejected, err := q.uploader.Sync(ctx, q.store, c.id, c.endpoint)
+		sent += n
+		if rejected > 0 {
+			q.mu.Lock()
+			q.dropped += rejected
+			q.mu.Unlock()
+		}
+		if err != nil {
+			q.failed(err)
+			return sent, err
+		}
+	}
+	q.succeeded()
+	return sent, nil
+}
+
+// trim drops the waiting results of check beyond the size and age limits by acknowledging them
+func (q *Queue) trim(check string) error {
+	if q.options.MaxSize <= 0 && q.options.MaxAge <= 0 {
+		return nil
+	}
+	last, err := q.store.Acknowledged(ackName(check))
+	if err != nil {
+		return err
+	}
+	items, err := q.store.Backlog(check, last)
+	if err != nil {
+		return err
+	}
+
+	drop := 0
+	if q.options.MaxSize > 0 && len(items) > q.options.MaxSize {
+		drop = len(items) - q.options.MaxSize
+	}
+	if q.options.MaxAge > 0 {
+		cutoff := time.Now().Add(-q.options.MaxAge)
+		for drop < len(items) && items[drop].Timestamp.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return nil
+	}
+
+	if err := q.store.Acknowledge(ackName(check), items[drop-1].ID); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.dropped += drop
+	q.mu.Unlock()
+	fmt.Printf("Warning: Dropped %d %s result(s) from the upload queue (limits: %d results, %s)\n",
+		drop, check, q.options.MaxSize, q.options.MaxAge)
+	return nil
+}
+
+func (q *Queue) failed(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failures++
+	q.lastErr = err
+	q.nextRetry = time.Now().Add(q.backoff(q.failures))
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) succeeded() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failures = 0
+	q.lastErr = nil
+	q.nextRetry = time.Time{}
+}
+
+// backoff returns the delay after the given number of consecutive failures: MinBackoff doubled
+// for every further failure up to MaxBackoff, of which the second half is random
+func (q *Queue) backoff(failures int) time.Duration {
+	delay := q.options.MinBackoff
+	for i := 1; i < failures && delay < q.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if q.options.MaxBackoff > 0 && delay > q.options.MaxBackoff {
+		delay = q.options.MaxBackoff
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// Run uploads the results left from earlier runs and retries failed uploads until ctx is done
+func (q *Queue) Run(ctx context.Context) {
+	if n, err := q.flush(ctx, ""); err != nil {
+		fmt.Printf("Warning: Failed to send queued results to dashboard: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("Sent %d queued result(s) to the dashboard\n", n)
+	}
+
+	for {
+		q.mu.Lock()
+		failures, nextRetry := q.failures, q.nextRetry
+		q.mu.Unlock()
+
+		var retry <-chan time.Time
+		if failures > 0 {
+			retry = time.After(time.Until(nextRetry))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+			continue
+		case <-retry:
+		}
+
+		n, err := q.flush(ctx, "")
+		if err != nil {
+			if ctx.Err() == nil {
+				q.mu.Lock()
+				delay := time.Until(q.nextRetry).Round(time.Second)
+				q.mu.Unlock()
+				fmt.Printf("Warning: Dashboard still unreachable, retrying in %s: %v\n", delay, err)
+			}
+			continue
+		}
+		fmt.Printf("Dashboard reachable again, sent %d queued result(s)\n", n)
+	}
+}
+
+// Status returns the number of results waiting and the state of the retries
+func (q *Queue) Status() QueueStatus {
+	q.mu.Lock()
+	status := QueueStatus{Dropped: q.dropped, Failures: q.failures}
+	if q.failures > 0 {
+		nextRetry := q.nextRetry
+		status.NextRetry = &nextRetry
+	}
+	if q.lastErr != nil {
+		status.LastError = q.lastErr.Error()
+	}
+	q.mu.Unlock()
+
+	for _, c := range q.checks {
+		n, err := q.Depth(c.id)
+		if err != nil {
+			status.LastError = err.Error()
+		}
+		status.Pending += n
+	}
+	return status
+}
+
+// Depth returns the number of results of check waiting for upload, within the queue limits
+func (q *Queue) Depth(check string) (int, error) {
+	last, err := q.store.Acknowledged(ackName(check))
+	if err != nil {
+		return 0, err
+	}
+	items, err := q.store.Backlog(check, last)
+	if err != nil {
+		return 0, err
+	}
+	n := len(items)
+	if q.options.MaxSize > 0 && n > q.options.MaxSize {
+		n = q.options.MaxSize
+	}
+	return n, nil
+}
+
+// ackName is the name the last uploaded result of check is acknowledged under in the store
+func ackName(check string) string {
+	return "upload:" + check
+}
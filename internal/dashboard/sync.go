@@ -0,0 +1,101 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"dlpagent/internal/store"
+)
+
+// upload is the body of a result upload: the agent and the entries of the results file of the
+// check, each with the stable ID the dashboard deduplicates them by and the facts of its host
+type upload struct {
+	AgentID string            `json:"agent_id,omitempty"`
+	Results []json.RawMessage `json:"results"`
+}
+
+// Sync uploads the results of check stored since its last acknowledged upload to endpoint,
+// in batches of BatchSize, oldest first. Each batch the server accepts is acknowledged in the
+// store, so a result is sent again only when its batch failed with an error worth retrying.
+// A batch the server rejects for good (see Retryable) is acknowledged as well and dropped.
+// Sync returns the number of results uploaded and dropped. Records without data, as imported
+// by older agents, are skipped.
+func (u *Uploader) Sync(ctx context.Context, st *store.Store, check, endpoint string) (sent, rejected int, err error) {
+	last, err := st.Acknowledged(ackName(check))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for {
+		records, err := st.Pending(check, last, u.BatchSize)
+		if err != nil {
+			return sent, rejected, err
+		}
+		if len(records) == 0 {
+			return sent, rejected, nil
+		}
+
+		body := upload{AgentID: u.AgentID}
+		hosts := map[string]json.RawMessage{}
+		for _, record := range records {
+			if len(record.Data) == 0 {
+				// Nothing to send; the record is acknowledged with its batch
+				fmt.Printf("Warning: Not sending %s result %d, it has no data\n", check, record.ID)
+				continue
+			}
+			if _, ok := hosts[record.HostID]; !ok && record.HostID != "" {
+				// A host that cannot be read must not hold up the queue; its results are sent without it
+				host, err := st.Host(record.HostID)
+				if err != nil {
+					fmt.Printf("Warning: Sending %s results without host facts: %v\n", check, err)
+				}
+				hosts[record.HostID] = host
+			}
+			entry, err := uploadEntry(record, hosts[record.HostID])
+			if err != nil {
+				return sent, rejected, fmt.Errorf("record %d: %w", record.ID, err)
+			}
+			body.Results = append(body.Results, entry)
+		}
+		accepted := true
+		if len(body.Results) > 0 {
+			if _, err := u.UploadJSON(ctx, endpoint, body); err != nil {
+				if Retryable(err) {
+					return sent, rejected, err
+				}
+				// Sending the batch again would be rejected again and hold up every later result
+				fmt.Printf("Warning: Dropping %d %s result(s) the dashboard rejected: %v\n", len(body.Results), check, err)
+				accepted = false
+			}
+		}
+
+		last = records[len(records)-1].ID
+		if err := st.Acknowledge(ackName(check), last); err != nil {
+			return sent, rejected, err
+		}
+		if accepted {
+			sent += len(body.Results)
+		} else {
+			rejected += len(body.Results)
+		}
+		if u.BatchSize <= 0 || len(records) < u.BatchSize {
+			return sent, rejected, nil
+		}
+	}
+}
+
+// uploadEntry returns the check-specific entry of record with its entry_id and the facts of the
+// host it was produced on added
+func uploadEntry(record store.Record, host json.RawMessage) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(record.Data, &fields); err != nil {
+		return nil, err
+	}
+	id, _ := json.Marshal(record.EntryID())
+	fields["entry_id"] = id
+	if len(host) > 0 {
+		fields["host"] = host
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,152 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dlpagent/internal/history"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/store"
+)
+
+func TestSyncUnreadableHost(t *testing.T) {
+	var uploads []upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body upload
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		uploads = append(uploads, body)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "results.db")
+	oldKey, _ := seal.NewKey([]byte("0123456789abcdef-old"))
+	key, _ := seal.NewKey([]byte("0123456789abcdef-new"))
+
+	// The host was sealed with a key the agent no longer has
+	hostID, err := store.New(path, store.Options{Key: oldKey}).AddHost(json.RawMessage(`{"hostname": "agent"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := store.New(path, store.Options{Key: key})
+	record := store.Record{
+		Entry:  history.Entry{Timestamp: time.Now(), Check: "dlp"},
+		Data:   json.RawMessage(`{"file_name": "card.txt"}`),
+		HostID: hostID,
+	}
+	if err := st.Add(record); err != nil {
+		t.Fatal(err)
+	}
+
+	uploader := NewUploader(server.URL, server.Client())
+	uploader.Compress = false
+	sent, _, err := uploader.Sync(context.Background(), st, "dlp", "/api/dlp")
+	if err != nil || sent != 1 || len(uploads) != 1 {
+		t.Fatalf("Sync() = %d, %v, want 1 result sent", sent, err)
+	}
+	var entry map[string]json.RawMessage
+	if err := json.Unmarshal(uploads[0].Results[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entry["host"]; ok {
+		t.Errorf("entry = %s, want no host", uploads[0].Results[0])
+	}
+	if _, ok := entry["entry_id"]; !ok {
+		t.Errorf("entry = %s, want an entry_id", uploads[0].Results[0])
+	}
+}
+
+func TestSyncSkipsEmptyData(t *testing.T) {
+	var uploads []upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body upload
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		uploads = append(uploads, body)
+	}))
+	defer server.Close()
+
+	st := store.New(filepath.Join(t.TempDir(), "results.db"), store.Options{})
+	now := time.Now()
+	err := st.Add(
+		store.Record{Entry: history.Entry{Timestamp: now, Check: "dlp"}},
+		store.Record{Entry: history.Entry{Timestamp: now, Check: "dlp"}, Data: json.RawMessage(`{"file_name": "card.txt"}`)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader := NewUploader(server.URL, server.Client())
+	uploader.Compress = false
+	sent, _, err := uploader.Sync(context.Background(), st, "dlp", "/api/dlp")
+	if err != nil || sent != 1 || len(uploads) != 1 || len(uploads[0].Results) != 1 {
+		t.Fatalf("Sync() = %d, %v with uploads %+v, want only the record with data sent", sent, err, uploads)
+	}
+
+	// The skipped record is not sent again
+	if sent, _, err := uploader.Sync(context.Background(), st, "dlp", "/api/dlp"); err != nil || sent != 0 {
+		t.Errorf("second Sync() = %d, %v, want nothing to send", sent, err)
+	}
+}
+
+func TestSyncRejectedBatch(t *testing.T) {
+	tests := []struct {
+		status  int
+		dropped bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			st := store.New(filepath.Join(t.TempDir(), "results.db"), store.Options{})
+			record := store.Record{
+				Entry: history.Entry{Timestamp: time.Now(), Check: "dlp"},
+				Data:  json.RawMessage(`{"file_name": "card.txt"}`),
+			}
+			if err := st.Add(record); err != nil {
+				t.Fatal(err)
+			}
+
+			uploader := NewUploader(server.URL, server.Client())
+			uploader.Compress = false
+			sent, rejected, err := uploader.Sync(context.Background(), st, "dlp", "/api/dlp")
+			if tt.dropped {
+				if err != nil || sent != 0 || rejected != 1 {
+					t.Fatalf("Sync() = %d, %d, %v, want the result dropped", sent, rejected, err)
+				}
+			} else if err == nil || rejected != 0 {
+				t.Fatalf("Sync() = %d, %d, %v, want an error to retry", sent, rejected, err)
+			}
+
+			// A dropped batch is not sent again; one worth retrying is
+			sent, rejected, err = uploader.Sync(context.Background(), st, "dlp", "/api/dlp")
+			want := 2
+			if tt.dropped {
+				want = 1
+			}
+			if requests != want {
+				t.Errorf("second Sync() = %d, %d, %v after %d request(s), want %d", sent, rejected, err, requests, want)
+			}
+		})
+	}
+}
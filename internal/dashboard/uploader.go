@@ -0,0 +1,137 @@
+package dashboard
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Result upload endpoints relative to the server URL
+const (
+	AntivirusEndpoint  = "/api/antivirus/get-data"
+	EDREndpoint        = "/api/edr/get-data"
+	DLPEndpoint        = "/api/dlp/get-data"
+	RansomwareEndpoint = "/api/ransomware/get-data"
+	ScheduleEndpoint   = "/api/agent/schedule"
+)
+
+// DefaultBatchSize is the number of results sent in one upload request
+const DefaultBatchSize = 100
+
+// Uploader sends results to the dashboard. Request bodies are gzip-compressed unless Compress
+// is off or the server has answered 415 Unsupported Media Type to a compressed request.
+type Uploader struct {
+	serverURL string
+	client    *http.Client
+	BatchSize int
+	Compress  bool
+	AgentID   string // identifies the agent in uploaded results; empty before enrollment
+
+	plain atomic.Bool // the server does not accept compressed requests
+}
+
+// NewUploader creates an uploader for the server at serverURL. A nil httpClient uses a default client.
+func NewUploader(serverURL string, httpClient *http.Client) *Uploader {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Uploader{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		client:    httpClient,
+		BatchSize: DefaultBatchSize,
+		Compress:  true,
+	}
+}
+
+// UploadJSON posts v encoded as JSON to the given dashboard endpoint and returns the response body
+func (u *Uploader) UploadJSON(ctx context.Context, endpoint string, v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode upload: %w", err)
+	}
+	return u.post(ctx, endpoint, data)
+}
+
+// post sends data to endpoint, compressed if enabled, and returns the response body
+func (u *Uploader) post(ctx context.Context, endpoint string, data []byte) (string, error) {
+	if u.Compress && !u.plain.Load() {
+		body, status, err := u.send(ctx, endpoint, data, true)
+		if status != http.StatusUnsupportedMediaType {
+			return body, err
+		}
+		u.plain.Store(true)
+	}
+	body, _, err := u.send(ctx, endpoint, data, false)
+	return body, err
+}
+
+func (u *Uploader) send(ctx context.Context, endpoint string, data []byte, compress bool) (string, int, error) {
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return "", 0, fmt.Errorf("failed to compress upload: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.serverURL+endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to upload results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", resp.StatusCode, fmt.Errorf("failed to read upload response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return string(bodyBytes), resp.StatusCode, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return string(bodyBytes), resp.StatusCode, nil
+}
+
+// StatusError is an upload the server answered with a status other than 2xx
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "upload failed with status " + e.Status
+}
+
+// Retryable reports whether an upload that failed with err may succeed when sent again: after
+// a network error, 408 Request Timeout, 429 Too Many Requests or a server error. Any other
+// status rejects the upload itself, and sending it again would fail the same way.
+func Retryable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch code := statusErr.StatusCode; {
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+		return true
+	}
+	return false
+}
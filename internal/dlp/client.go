@@ -2,10 +2,13 @@ package dlp
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,31 +16,47 @@ type HTTPClient struct {
 	client *http.Client
 }
 
-func NewHTTPClient() *HTTPClient {
+// NewHTTPClient creates a client for test traffic. A nil httpClient uses a client with a one minute timeout.
+func NewHTTPClient(httpClient *http.Client) *HTTPClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Minute}
+	}
 	return &HTTPClient{
-		client: &http.Client{},
+		client: httpClient,
 	}
 }
 
-func (c *HTTPClient) SendRequest(req *CheckRequest) (*CheckResponse, error) { // bu gedecek EvaluateRequest funksiyasina
-	httpReq, err := c.buildRequest(req)
+func (c *HTTPClient) SendRequest(ctx context.Context, req *CheckRequest) (*CheckResponse, error) { // bu gedecek EvaluateRequest funksiyasina
+	httpReq, err := c.buildRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
+	// Only a reset after the whole request was written can be DLP inspecting the file
+	var written atomic.Bool
+	trace := &httptrace.ClientTrace{WroteRequest: func(info httptrace.WroteRequestInfo) {
+		written.Store(info.Err == nil)
+	}}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
+
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
+		if written.Load() && isReset(err) {
+			return nil, fmt.Errorf("%w: %v", errReset, err)
+		}
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	return &CheckResponse{
-		StatusCode: resp.StatusCode,
-		StatusText: resp.Status,
+		StatusCode:  resp.StatusCode,
+		StatusText:  resp.Status,
+		ContentType: resp.Header.Get("Content-Type"),
+		Via:         resp.Header.Get("Via"),
 	}, nil
 }
 
-func (c *HTTPClient) buildRequest(req *CheckRequest) (*http.Request, error) {
+func (c *HTTPClient) buildRequest(ctx context.Context, req *CheckRequest) (*http.Request, error) {
 	var httpReq *http.Request
 	var err error
 
@@ -72,7 +91,7 @@ func (c *HTTPClient) buildRequest(req *CheckRequest) (*http.Request, error) {
 		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
 	}
 
-	httpReq, err = http.NewRequest(req.HTTPMethod, req.TestURL, body)
+	httpReq, err = http.NewRequestWithContext(ctx, req.HTTPMethod, req.TestURL, body)
 	if err != nil {
 		return nil, err
 	}
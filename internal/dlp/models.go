@@ -10,15 +10,20 @@ type CheckRequest struct {
 }
 
 type CheckResponse struct {
-	StatusCode int
-	StatusText string
+	StatusCode  int
+	StatusText  string
+	ContentType string // Content-Type of the response, used to recognise block pages
+	Via         string // Via header, set when a proxy or gateway relayed the response
 }
 
 type Result struct {
 	IsDLPActive bool
+	TimedOut    bool // no answer within the deadline; neither blocked nor allowed
+	Error       bool // the request failed before DLP could inspect it; neither blocked nor allowed
 	StatusText  string
 	IP          string // IP address of the computer sending the request
 	FileContent string // content of the file
+	Route       string // "proxy" or "direct" when both paths are compared
 }
 
 // CheckResultEntry represents a single result entry stored in JSON
@@ -26,10 +31,13 @@ type CheckResultEntry struct {
 	Timestamp   time.Time `json:"timestamp"`
 	StatusText  string    `json:"status_text"`
 	IsDLPActive bool      `json:"is_dlp_active"`
+	TimedOut    bool      `json:"timed_out,omitempty"`
+	Error       bool      `json:"error,omitempty"`
 	FileName    string    `json:"file_name"`
 	Category    string    `json:"category"`
 	IP          string    `json:"ip"`
 	FileContent string    `json:"file_content"`
+	Route       string    `json:"route,omitempty"`
 }
 
 // CheckResultsHistory stores the history of check results
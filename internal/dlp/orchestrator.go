@@ -1,44 +1,35 @@
 package dlp
 
 import (
-	"encoding/json"
-	"fmt"
-	"net"
+	"context"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+
+	"dlpagent/internal/host"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/seal"
 )
 
 type Orchestrator struct {
 	client *HTTPClient
 }
 
-func NewOrchestrator() *Orchestrator {
+// NewOrchestrator creates an orchestrator sending test traffic with httpClient (nil for a default client)
+func NewOrchestrator(httpClient *http.Client) *Orchestrator {
 	return &Orchestrator{
-		client: NewHTTPClient(),
-	}
-}
-
-// getLocalIP returns the local IP address of the machine
-func getLocalIP() string {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil {
-		return "127.0.0.1"
+		client: NewHTTPClient(httpClient),
 	}
-	defer conn.Close()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String()
 }
 
-func (o *Orchestrator) RunDLPCheck(testFile, testURL, httpMethod string) *Result {
+func (o *Orchestrator) RunDLPCheck(ctx context.Context, testFile, testURL, httpMethod string) *Result {
 	fileContent, err := os.ReadFile(testFile)
 	if err != nil {
 		return &Result{
-			IsDLPActive: true,
+			Error:       true,
 			StatusText:  "Failed to read file: " + err.Error(),
-			IP:          getLocalIP(),
+			IP:          host.PrimaryIP(),
 			FileContent: "",
 		}
 	}
@@ -53,13 +44,13 @@ func (o *Orchestrator) RunDLPCheck(testFile, testURL, httpMethod string) *Result
 		FileExtension: fileExt,
 	}
 
-	resp, err := o.client.SendRequest(req)
+	resp, err := o.client.SendRequest(ctx, req)
 	result := EvaluateResult(resp, err)
-	
+
 	// Set IP and file content
-	result.IP = getLocalIP()
+	result.IP = host.PrimaryIP()
 	result.FileContent = string(fileContent)
-	
+
 	return result
 }
 
@@ -80,50 +71,35 @@ func getCategory(fileName string) string {
 	return "unknown"
 }
 
-// SaveResultToJSON saves the result to JSON file, keeping only last 15 entries
-func (o *Orchestrator) SaveResultToJSON(result *Result, jsonFilePath string, fileName string) error {
+// NewCheckResultEntry returns the stored form of the result of a file
+func NewCheckResultEntry(r FileResult) CheckResultEntry {
+	return CheckResultEntry{
+		Timestamp:   r.Timestamp,
+		StatusText:  r.Result.StatusText,
+		IsDLPActive: r.Result.IsDLPActive,
+		TimedOut:    r.Result.TimedOut,
+		Error:       r.Result.Error,
+		FileName:    filepath.Base(r.File),
+		Category:    getCategory(r.File),
+		IP:          r.Result.IP,
+		FileContent: r.Result.FileContent,
+		Route:       r.Result.Route,
+	}
+}
+
+// SaveResultsToJSON appends the entries of a run to the JSON file in a single write, keeping the
+// last 15 entries or the whole run if it is longer
+func (o *Orchestrator) SaveResultsToJSON(entries []CheckResultEntry, jsonFilePath string, key *seal.Key) error {
 	history := &CheckResultsHistory{
 		Results: []CheckResultEntry{},
 	}
+	return jsonfile.Update(jsonFilePath, key, history, func() error {
+		history.Results = append(history.Results, entries...)
 
-	// Read existing results if file exists
-	if _, err := os.Stat(jsonFilePath); err == nil {
-		data, err := os.ReadFile(jsonFilePath)
-		if err == nil {
-			json.Unmarshal(data, history)
+		// Keep only last 15 entries, but never drop part of this run
+		if keep := max(15, len(entries)); len(history.Results) > keep {
+			history.Results = history.Results[len(history.Results)-keep:]
 		}
-	}
-
-	// Create new entry
-	category := getCategory(fileName)
-	entry := CheckResultEntry{
-		Timestamp:   time.Now(),
-		StatusText:  result.StatusText,
-		IsDLPActive: result.IsDLPActive,
-		FileName:    filepath.Base(fileName),
-		Category:    category,
-		IP:          result.IP,
-		FileContent: result.FileContent,
-	}
-
-	// Add new entry
-	history.Results = append(history.Results, entry)
-
-	// Keep only last 15 entries
-	if len(history.Results) > 15 {
-		history.Results = history.Results[len(history.Results)-15:]
-	}
-
-	// Save to JSON file
-	data, err := json.MarshalIndent(history, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	err = os.WriteFile(jsonFilePath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write JSON file: %w", err)
-	}
-
-	return nil
+		return nil
+	})
 }
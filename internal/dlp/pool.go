@@ -0,0 +1,148 @@
+package dlp
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PoolOptions bound how many requests a run sends at once and how fast
+type PoolOptions struct {
+	Concurrency int     // requests in flight at once, at least 1
+	RateLimit   float64 // requests per second to each target host, 0 for no limit
+}
+
+// FileResult is the result of checking one file of a run
+type FileResult struct {
+	Index     int // position of the file in the run
+	File      string
+	Result    *Result
+	Timestamp time.Time
+}
+
+// RunFiles checks every file against testURL with a pool of opts.Concurrency workers.
+// emit is called from the calling goroutine with each result in the order of files, as soon
+// as the results before it are in. Files not checked by the time ctx is done are left out.
+func (o *Orchestrator) RunFiles(ctx context.Context, files []string, testURL, httpMethod string, opts PoolOptions, emit func(FileResult)) {
+	workers := min(max(opts.Concurrency, 1), len(files))
+	limiter := newRateLimiter(opts.RateLimit)
+	host := targetHost(testURL)
+
+	jobs := make(chan int)
+	results := make(chan FileResult, workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range jobs {
+				if err := limiter.Wait(ctx, host); err != nil {
+					continue
+				}
+				result := o.RunDLPCheck(ctx, files[i], testURL, httpMethod)
+				if ctx.Err() != nil {
+					// The request was cut short by the agent, not by DLP
+					continue
+				}
+				results <- FileResult{Index: i, File: files[i], Result: result, Timestamp: time.Now()}
+			}
+		})
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := map[int]FileResult{}
+	next := 0
+	for r := range results {
+		pending[r.Index] = r
+		for {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			emit(p)
+			next++
+		}
+	}
+
+	// After an interruption the results following a missing file are still reported in order
+	rest := make([]FileResult, 0, len(pending))
+	for _, r := range pending {
+		rest = append(rest, r)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Index < rest[j].Index })
+	for _, r := range rest {
+		emit(r)
+	}
+}
+
+// targetHost returns the host requests to testURL are rate limited by
+func targetHost(testURL string) string {
+	u, err := url.Parse(testURL)
+	if err != nil || u.Host == "" {
+		return testURL
+	}
+	return u.Host
+}
+
+// rateLimiter spaces the requests to each host at least 1/rate apart. A nil rateLimiter does not limit.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		next:     map[string]time.Time{},
+	}
+}
+
+// Wait blocks until a request to host may be sent or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	slot := l.next[host]
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,143 @@
+package dlp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFiles writes n files whose content is their index
+func testFiles(t *testing.T, n int) []string {
+	dir := t.TempDir()
+	files := make([]string, n)
+	for i := range files {
+		files[i] = filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(files[i], []byte(strconv.Itoa(i)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestRunFiles(t *testing.T) {
+	const files = 12
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		// Later files answer first, so results arrive out of order
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		i, _ := strconv.Atoi(string(content))
+		time.Sleep(time.Duration(files-i) * 5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{"sequential", 1},
+		{"pool", 4},
+		{"more workers than files", 50},
+	}
+	for _, tt := range tests {
+		maxInFlight = 0
+		var got []int
+		o := NewOrchestrator(server.Client())
+		o.RunFiles(context.Background(), testFiles(t, files), server.URL, "POST", PoolOptions{Concurrency: tt.concurrency}, func(r FileResult) {
+			got = append(got, r.Index)
+		})
+		if len(got) != files {
+			t.Fatalf("%s: %d results, want %d", tt.name, len(got), files)
+		}
+		for i, index := range got {
+			if index != i {
+				t.Errorf("%s: results in order %v, want the order of the files", tt.name, got)
+				break
+			}
+		}
+		if maxInFlight > tt.concurrency {
+			t.Errorf("%s: %d requests in flight, want at most %d", tt.name, maxInFlight, tt.concurrency)
+		}
+	}
+}
+
+func TestRunFilesInterrupted(t *testing.T) {
+	// The requests hang until the test ends
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var got []FileResult
+	o := NewOrchestrator(server.Client())
+	o.RunFiles(ctx, testFiles(t, 8), server.URL, "POST", PoolOptions{Concurrency: 2}, func(r FileResult) {
+		got = append(got, r)
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RunFiles() returned after %s, want shortly after the interruption", elapsed)
+	}
+	// Requests cut short by the agent are not DLP blocks, so they are left out
+	if len(got) != 0 {
+		t.Errorf("RunFiles() emitted %d results of interrupted requests", len(got))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		hosts []string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"no limit", 0, []string{"a", "a", "a", "a", "a"}, 0, 50 * time.Millisecond},
+		{"one host", 20, []string{"a", "a", "a", "a", "a"}, 200 * time.Millisecond, time.Second},
+		{"each host on its own", 20, []string{"a", "b", "c", "d", "e"}, 0, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		l := newRateLimiter(tt.rate)
+		start := time.Now()
+		for _, host := range tt.hosts {
+			if err := l.Wait(context.Background(), host); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+			t.Errorf("%s: %d requests took %s, want %s to %s", tt.name, len(tt.hosts), elapsed, tt.min, tt.max)
+		}
+	}
+
+	// A cancelled wait returns at once
+	l := newRateLimiter(0.1)
+	l.Wait(context.Background(), "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "a"); err == nil {
+		t.Error("Wait() with a cancelled context = nil error")
+	}
+}
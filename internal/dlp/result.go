@@ -1,12 +1,65 @@
 package dlp
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// errReset marks a connection cut after the whole request was sent, which is how DLP proxies
+// and gateways stop an upload once they have inspected it
+var errReset = errors.New("connection reset after the request was sent")
 
 func EvaluateResult(resp *CheckResponse, err error) *Result {
 	if err != nil {
+		// A timeout says nothing about DLP, so it is not reported as a block
+		if isTimeout(err) {
+			return &Result{
+				IsDLPActive: false,
+				TimedOut:    true,
+				StatusText:  fmt.Sprintf("Request timed out: %v", err),
+				IP:          "",
+				FileContent: "",
+			}
+		}
+
+		if errors.Is(err, errReset) {
+			return &Result{
+				IsDLPActive: true,
+				StatusText:  fmt.Sprintf("DLP blocked request: %v", err),
+				IP:          "",
+				FileContent: "",
+			}
+		}
+
+		// DNS failures, refused connections and TLS errors happen before DLP sees the file
+		return &Result{
+			IsDLPActive: false,
+			Error:       true,
+			StatusText:  fmt.Sprintf("Request failed: %v", err),
+			IP:          "",
+			FileContent: "",
+		}
+	}
+
+	if isBlockResponse(resp) {
 		return &Result{
 			IsDLPActive: true,
-			StatusText:  fmt.Sprintf("DLP blocked request: %v", err),
+			StatusText:  fmt.Sprintf("DLP block page returned: %s", resp.StatusText),
+			IP:          "",
+			FileContent: "",
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return &Result{
+			IsDLPActive: false,
+			Error:       true,
+			StatusText:  fmt.Sprintf("Request failed: %s", resp.StatusText),
 			IP:          "",
 			FileContent: "",
 		}
@@ -19,3 +72,31 @@ func EvaluateResult(resp *CheckResponse, err error) *Result {
 		FileContent: "",
 	}
 }
+
+// isBlockResponse reports whether resp is a denial by a DLP proxy or gateway: a 403 or 451
+// answered with an HTML page or through a proxy. A plain denial by the test server is an error.
+func isBlockResponse(resp *CheckResponse) bool {
+	if resp.StatusCode != 403 && resp.StatusCode != 451 {
+		return false
+	}
+	return strings.Contains(strings.ToLower(resp.ContentType), "text/html") || resp.Via != ""
+}
+
+// isReset reports whether err is the connection being reset or aborted by the peer. Windows
+// reports Winsock error numbers, which the syscall constants do not match there.
+func isReset(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) {
+		return true
+	}
+	var errno syscall.Errno
+	return runtime.GOOS == "windows" && errors.As(err, &errno) && (errno == 10053 || errno == 10054) // WSAECONNABORTED, WSAECONNRESET
+}
+
+// isTimeout reports whether err is a deadline or network timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
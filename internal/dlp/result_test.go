@@ -0,0 +1,72 @@
+package dlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// resetServer reads the whole request and resets the connection, like a DLP proxy stopping an upload
+func resetServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+}
+
+func TestSendAndEvaluate(t *testing.T) {
+	reset := resetServer(t)
+	defer reset.Close()
+	status := func(code int, contentType string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(code)
+		}))
+	}
+	allowed := status(200, "application/json")
+	defer allowed.Close()
+	blockPage := status(403, "text/html")
+	defer blockPage.Close()
+	denied := status(403, "application/json")
+	defer denied.Close()
+
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name   string
+		url    string
+		active bool
+		error  bool
+	}{
+		{"reset after the request", reset.URL, true, false},
+		{"block page", blockPage.URL, true, false},
+		{"allowed", allowed.URL, false, false},
+		{"denied by the test server", denied.URL, false, true},
+		{"connection refused", refused, false, true},
+		{"unknown host", "http://dlp-test.invalid/", false, true},
+	}
+	client := NewHTTPClient(nil)
+	for _, tt := range tests {
+		resp, err := client.SendRequest(context.Background(), &CheckRequest{TestFile: "4111 1111 1111 1111", TestURL: tt.url, HTTPMethod: "POST"})
+		result := EvaluateResult(resp, err)
+		if result.IsDLPActive != tt.active || result.Error != tt.error {
+			t.Errorf("%s: active = %v, error = %v (%s), want active %v, error %v",
+				tt.name, result.IsDLPActive, result.Error, result.StatusText, tt.active, tt.error)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package dlp
+
+import (
+	"os"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// SampleFiles are the names of the generated sample files, created in the working directory
+var SampleFiles = []string{
+	"test_credit_card.txt",
+	"test_passport.txt",
+	"test_dlp_data.csv",
+	"test_dlp_data.xlsx",
+}
+
+// PrepareFiles returns files, or the default sample files when none are given,
+// creating the samples if any of them is missing
+func PrepareFiles(files []string) []string {
+	if len(files) > 0 {
+		return files
+	}
+
+	defaultFiles := SampleFiles
+
+	allExist := true
+	for _, fileName := range defaultFiles {
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			allExist = false
+			break
+		}
+	}
+
+	if !allExist {
+		createCreditCardFile("test_credit_card.txt")
+		createPassportFile("test_passport.txt")
+		createCSVFile("test_dlp_data.csv")
+		createXLSXFile("test_dlp_data.xlsx")
+	}
+
+	return defaultFiles
+}
+
+func createCreditCardFile(fileName string) error {
+	content := `John Doe
+                12345
+                $50000
+                john.doe@example.com
+                4532-1234-5678-9010
+                123-45-6789
+                01/15/1980
+                `
+	return os.WriteFile(fileName, []byte(content), 0644)
+}
+
+func createPassportFile(path string) error {
+	content := `John Doe
+                AB1234567
+                01/15/1980
+                USA
+                `
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func createCSVFile(path string) error {
+	content := "Card Number,Expiry,CVV,Name\n" +
+		"4532-1234-5678-9010,12/27,123,John Doe\n"
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func createXLSXFile(path string) error {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+
+	f.SetCellValue(sheet, "A1", "Passport Number")
+	f.SetCellValue(sheet, "B1", "Name")
+	f.SetCellValue(sheet, "C1", "DOB")
+
+	f.SetCellValue(sheet, "A2", "AB1234567")
+	f.SetCellValue(sheet, "B2", "John Doe")
+	f.SetCellValue(sheet, "C2", "01/15/1980")
+
+	return f.SaveAs(path)
+}
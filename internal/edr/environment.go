@@ -0,0 +1,147 @@
+package edr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// environment is the per-simulation sandbox. Every file a simulation creates is
+// either inside root or registered for cleanup.
+type environment struct {
+	ctx      context.Context
+	root     string
+	options  Options
+	cleanups []string
+}
+
+func newEnvironment(ctx context.Context, options Options) (*environment, error) {
+	baseDir := options.BaseDir
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+
+	root, err := os.MkdirTemp(baseDir, "dlpagent-edr-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	return &environment{ctx: ctx, root: root, options: options}, nil
+}
+
+// path returns a path inside the sandbox, creating its parent directories
+func (e *environment) path(elem ...string) (string, error) {
+	path := filepath.Join(append([]string{e.root}, elem...)...)
+	if rel, err := filepath.Rel(e.root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("refusing to use %s outside the sandbox", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	return path, nil
+}
+
+// track registers a file outside the sandbox for removal during cleanup
+func (e *environment) track(path string) {
+	e.cleanups = append(e.cleanups, path)
+}
+
+// cleanup removes tracked files and the sandbox
+func (e *environment) cleanup() {
+	for _, path := range e.cleanups {
+		os.Remove(path)
+	}
+	os.RemoveAll(e.root)
+}
+
+// writeArtifact writes a file and reports it as prevented if the EDR blocks the write. A denied
+// write is only attributed to the EDR when a neutral file can be written next to it.
+func (e *environment) writeArtifact(path string, content []byte, perm os.FileMode) error {
+	if err := os.WriteFile(path, content, perm); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			probe, probeErr := os.CreateTemp(filepath.Dir(path), "probe-")
+			if probeErr != nil {
+				return fmt.Errorf("failed to write %s: %w", path, probeErr)
+			}
+			probe.Close()
+			os.Remove(probe.Name())
+			return prevented("write of %s blocked", path)
+		}
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// observe waits for the EDR and reports the artifact as prevented if it was removed
+func (e *environment) observe(path string) error {
+	select {
+	case <-time.After(e.options.ObserveDelay):
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return prevented("artifact %s removed", path)
+	}
+	return nil
+}
+
+// shell runs a command line with /bin/sh after checking that the tools it uses are installed.
+// The shell exits with 126 or 127 when a command cannot be run or is not found, so those codes
+// skip the simulation rather than count as executed.
+func (e *environment) shell(command string, tools ...string) (int, error) {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return 0, skipped("%s is not installed", tool)
+		}
+	}
+	code, err := e.run("/bin/sh", "-c", command)
+	if err == nil && (code == 126 || code == 127) {
+		return code, skipped("a command of %q could not be run (exit code %d)", command, code)
+	}
+	return code, err
+}
+
+// run executes a process and reports it as prevented if it was blocked or killed.
+// A non-zero exit code is not a failure, as several simulations expect one. Execution refused
+// on a filesystem mounted noexec, or of a program that is not installed, skips the simulation.
+func (e *environment) run(name string, args ...string) (int, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, e.options.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = e.root
+	cmd.Env = append(os.Environ(), "HOME="+filepath.Join(e.root, "home"))
+
+	err := cmd.Run()
+	if e.ctx.Err() != nil {
+		return 0, e.ctx.Err()
+	}
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf("%s timed out after %s", filepath.Base(name), e.options.Timeout)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 0, prevented("process %s killed by %s", filepath.Base(name), status.Signal())
+		}
+		return exitErr.ExitCode(), nil
+	case errors.Is(err, exec.ErrNotFound):
+		return 0, skipped("%s is not installed", name)
+	case errors.Is(err, os.ErrPermission):
+		if noexecMount(name) {
+			return 0, skipped("%s is on a filesystem mounted noexec", filepath.Dir(name))
+		}
+		return 0, prevented("execution of %s blocked", name)
+	}
+	return 0, fmt.Errorf("failed to run %s: %w", name, err)
+}
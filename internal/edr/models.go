@@ -0,0 +1,63 @@
+package edr
+
+import (
+	"time"
+
+	"dlpagent/internal/antivirus"
+)
+
+// Simulation is a harmless, self-contained behaviour simulation mapped to a MITRE ATT&CK technique
+type Simulation struct {
+	ID          string
+	TechniqueID string   // MITRE ATT&CK technique ID, e.g. T1053.003
+	Name        string   // technique name
+	Tactic      string   // MITRE ATT&CK tactic
+	Platforms   []string // GOOS values the simulation runs on
+	run         func(env *environment) (string, error)
+}
+
+type Result struct {
+	SimulationID string
+	TechniqueID  string
+	Name         string
+	Tactic       string
+	Verdict      Verdict
+	StatusText   string
+	Duration     time.Duration
+	IP           string // IP address of the computer running the simulation
+}
+
+// CheckResultEntry represents a single result entry stored in JSON
+type CheckResultEntry struct {
+	Timestamp    time.Time                 `json:"timestamp"`
+	SimulationID string                    `json:"simulation_id"`
+	TechniqueID  string                    `json:"technique_id"`
+	Name         string                    `json:"name"`
+	Tactic       string                    `json:"tactic"`
+	Verdict      Verdict                   `json:"verdict"`
+	StatusText   string                    `json:"status_text"`
+	DurationMs   int64                     `json:"duration_ms"`
+	IP           string                    `json:"ip"`
+	Controls     []antivirus.ControlResult `json:"controls"`
+}
+
+// CheckResultsHistory stores the history of check results
+type CheckResultsHistory struct {
+	Results []CheckResultEntry `json:"results"`
+}
+
+// Options controls how simulations are run
+type Options struct {
+	BaseDir      string        // directory sandboxes are created in (default: system temp dir)
+	ObserveDelay time.Duration // time to wait for the EDR to remove an artifact
+	Timeout      time.Duration // maximum run time of each simulated process
+	Techniques   []string      // run only these technique or simulation IDs (default: all)
+}
+
+// DefaultOptions returns the options used when none are given
+func DefaultOptions() Options {
+	return Options{
+		ObserveDelay: 3 * time.Second,
+		Timeout:      30 * time.Second,
+	}
+}
@@ -0,0 +1,138 @@
+package edr
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mount is a mounted filesystem and whether it allows executing programs
+type mount struct {
+	point  string
+	noexec bool
+}
+
+// noexecMount reports whether path is on a filesystem mounted noexec, where executing a program
+// is refused by the kernel, not the EDR. It returns false when the mounts cannot be read.
+func noexecMount(path string) bool {
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(resolved, filepath.Base(path))
+	}
+	var mounts []mount
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/self/mountinfo")
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		mounts = parseMountinfo(f)
+	case "darwin":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "mount").Output()
+		if err != nil {
+			return false
+		}
+		mounts = parseMountOutput(string(out))
+	}
+	return mountOf(mounts, path).noexec
+}
+
+// mountOf returns the mount with the longest mount point containing path
+func mountOf(mounts []mount, path string) mount {
+	var best mount
+	for _, m := range mounts {
+		if !within(path, m.point) || len(m.point) < len(best.point) {
+			continue
+		}
+		best = m
+	}
+	return best
+}
+
+func within(path, dir string) bool {
+	if dir == "/" {
+		return strings.HasPrefix(path, "/")
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// parseMountinfo parses /proc/self/mountinfo:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noexec,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// where the fifth field is the mount point, with spaces escaped as \040, and the sixth the
+// options of the mount
+func parseMountinfo(r io.Reader) []mount {
+	var mounts []mount
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mounts = append(mounts, mount{
+			point:  unescapeOctal(fields[4]),
+			noexec: hasOption(fields[5], ","),
+		})
+	}
+	return mounts
+}
+
+// parseMountOutput parses the output of mount on macOS:
+//
+//	/dev/disk3s1 on /Volumes/Data (apfs, local, noexec, journaled)
+func parseMountOutput(out string) []mount {
+	var mounts []mount
+	for _, line := range strings.Split(out, "\n") {
+		_, rest, ok := strings.Cut(line, " on ")
+		if !ok {
+			continue
+		}
+		i := strings.LastIndex(rest, " (")
+		if i < 0 {
+			continue
+		}
+		mounts = append(mounts, mount{
+			point:  rest[:i],
+			noexec: hasOption(strings.TrimSuffix(rest[i+2:], ")"), ", "),
+		})
+	}
+	return mounts
+}
+
+func hasOption(options, sep string) bool {
+	for _, option := range strings.Split(options, sep) {
+		if option == "noexec" {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeOctal decodes the \ooo escapes of mountinfo paths
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
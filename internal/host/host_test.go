@@ -0,0 +1,86 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMatchProducts(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []string
+		want      []Product
+	}{
+		{"none", []string{"bash", "sshd"}, []Product{}},
+		{"Windows executable", []string{"System", "MsMpEng.exe"}, []Product{{"Microsoft Defender", "MsMpEng.exe"}}},
+		{
+			"several, sorted by name",
+			[]string{"falcon-sensor", "clamd", "freshclam", "wazuh-agentd"},
+			[]Product{{"ClamAV", "clamd"}, {"CrowdStrike Falcon", "falcon-sensor"}, {"Wazuh", "wazuh-agentd"}},
+		},
+		{"prefix of a known name", []string{"clam", "avp-helper"}, []Product{}},
+	}
+	for _, tt := range tests {
+		if got := matchProducts(tt.processes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matchProducts(%q) = %v, want %v", tt.name, tt.processes, got, tt.want)
+		}
+	}
+}
+
+func TestFirstGlobal(t *testing.T) {
+	tests := []struct {
+		ips  []string
+		ipv4 bool
+		want string
+	}{
+		{[]string{"127.0.0.1", "fe80::1", "192.168.1.10"}, true, "192.168.1.10"},
+		{[]string{"2001:db8::1", "10.0.0.5"}, true, "10.0.0.5"},
+		{[]string{"2001:db8::1", "10.0.0.5"}, false, "2001:db8::1"},
+		{[]string{"169.254.1.1", "::1"}, false, ""},
+		{[]string{"not an address"}, false, ""},
+		{nil, true, ""},
+	}
+	for _, tt := range tests {
+		if got := firstGlobal(tt.ips, tt.ipv4); got != tt.want {
+			t.Errorf("firstGlobal(%q, %v) = %q, want %q", tt.ips, tt.ipv4, got, tt.want)
+		}
+	}
+}
+
+func TestAgentVersion(t *testing.T) {
+	defer func(version, commit string) { Version, Commit = version, commit }(Version, Commit)
+
+	Version, Commit = "1.4.0", "abc123"
+	if version, commit := AgentVersion(); version != "1.4.0" || commit != "abc123" {
+		t.Errorf("AgentVersion() = %s, %s, want the build time values", version, commit)
+	}
+	Version, Commit = "", ""
+	if version, _ := AgentVersion(); version == "" {
+		t.Error("AgentVersion() without a build time version = \"\", want dev or the module version")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	facts := Collect(context.Background())
+	if facts.OS != runtime.GOOS || facts.Arch != runtime.GOARCH || facts.AgentVersion == "" {
+		t.Errorf("Collect() = %+v, want the OS, architecture and agent version", facts)
+	}
+	for _, iface := range facts.Interfaces {
+		if strings.HasPrefix(iface.Name, "lo") && len(iface.IPs) > 0 && iface.IPs[0] == "127.0.0.1" {
+			t.Errorf("Collect() lists the loopback interface %s", iface.Name)
+		}
+	}
+
+	// The dashboard expects a list, even when no product is found
+	data, err := json.Marshal(facts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"security_products":null`) {
+		t.Errorf("Collect() JSON = %s, want security_products as a list", data)
+	}
+}
@@ -0,0 +1,116 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Product is a security product found running on the host
+type Product struct {
+	Name    string `json:"name"`
+	Process string `json:"process"` // the running process it was recognised by
+}
+
+// products maps the process names of known security products, in lower case and without
+// .exe, to the product
+var products = map[string]string{
+	// ClamAV
+	"clamd": "ClamAV", "freshclam": "ClamAV", "clamonacc": "ClamAV",
+	// CrowdStrike Falcon
+	"falcond": "CrowdStrike Falcon", "falcon-sensor": "CrowdStrike Falcon", "csfalconservice": "CrowdStrike Falcon",
+	"com.crowdstrike.falcon.agent": "CrowdStrike Falcon",
+	// SentinelOne
+	"sentinelagent": "SentinelOne", "sentinelservicehost": "SentinelOne", "s1-agent": "SentinelOne",
+	"s1-orchestrator": "SentinelOne", "sentineld": "SentinelOne",
+	// Microsoft Defender
+	"msmpeng": "Microsoft Defender", "mssense": "Microsoft Defender", "wdavdaemon": "Microsoft Defender",
+	// Sophos
+	"savservice": "Sophos", "sophoshealth": "Sophos", "sophos_threat_detector": "Sophos", "savd": "Sophos",
+	// VMware Carbon Black
+	"cbagentd": "Carbon Black", "repmgr": "Carbon Black", "cbdefense": "Carbon Black",
+	// Elastic Defend
+	"elastic-endpoint": "Elastic Defend",
+	// Trend Micro
+	"ds_agent": "Trend Micro Deep Security", "ntrtscan": "Trend Micro Apex One",
+	// ESET
+	"ekrn": "ESET", "esets_daemon": "ESET", "eset_rtp": "ESET",
+	// Kaspersky
+	"avp": "Kaspersky", "kesl": "Kaspersky",
+	// Cortex XDR
+	"cyserver": "Palo Alto Cortex XDR", "traps_pmd": "Palo Alto Cortex XDR",
+	// Wazuh
+	"wazuh-agentd": "Wazuh", "wazuh-agent": "Wazuh",
+}
+
+// detectProducts returns the known security products with a running process, sorted by name
+func detectProducts(ctx context.Context) []Product {
+	return matchProducts(processes(ctx))
+}
+
+// matchProducts returns the known security products among the process names, sorted by name,
+// each with the first process it was recognised by
+func matchProducts(processes []string) []Product {
+	found := map[string]string{}
+	for _, process := range processes {
+		key := strings.TrimSuffix(strings.ToLower(process), ".exe")
+		if name, ok := products[key]; ok {
+			if _, seen := found[name]; !seen {
+				found[name] = process
+			}
+		}
+	}
+
+	detected := make([]Product, 0, len(found))
+	for name, process := range found {
+		detected = append(detected, Product{Name: name, Process: process})
+	}
+	sort.Slice(detected, func(i, j int) bool {
+		return detected[i].Name < detected[j].Name
+	})
+	return detected
+}
+
+// processes returns the executable names of the running processes
+func processes(ctx context.Context) []string {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxProcesses()
+	case "windows":
+		// "Image Name","PID","Session Name","Session#","Mem Usage"
+		records, _ := csv.NewReader(strings.NewReader(output(ctx, "tasklist", "/fo", "csv", "/nh"))).ReadAll()
+		var names []string
+		for _, record := range records {
+			names = append(names, record[0])
+		}
+		return names
+	}
+	var names []string
+	for _, line := range strings.Split(output(ctx, "ps", "-axo", "comm="), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, filepath.Base(line))
+		}
+	}
+	return names
+}
+
+// linuxProcesses reads the process names from /proc. The command line is used rather than
+// comm, which is cut at 15 characters; kernel threads have none.
+func linuxProcesses() []string {
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	var names []string
+	for _, dir := range dirs {
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+		names = append(names, filepath.Base(string(argv0)))
+	}
+	return names
+}
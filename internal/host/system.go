@@ -0,0 +1,79 @@
+package host
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// system returns the name and version of the operating system and the kernel version
+func system(ctx context.Context) (distro, kernel string) {
+	switch runtime.GOOS {
+	case "linux":
+		data, _ := os.ReadFile("/proc/sys/kernel/osrelease")
+		return osRelease(), strings.TrimSpace(string(data))
+	case "darwin":
+		name := output(ctx, "sw_vers", "-productName")
+		if version := output(ctx, "sw_vers", "-productVersion"); version != "" {
+			name = strings.TrimSpace(name + " " + version)
+		}
+		return name, output(ctx, "uname", "-r")
+	case "windows":
+		return windowsProduct(ctx), windowsKernel(ctx)
+	}
+	return "", output(ctx, "uname", "-r")
+}
+
+// osRelease returns the PRETTY_NAME of /etc/os-release, or NAME and VERSION
+func osRelease() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		if f, err = os.Open("/usr/lib/os-release"); err != nil {
+			return ""
+		}
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"'`)
+		}
+	}
+	if values["PRETTY_NAME"] != "" {
+		return values["PRETTY_NAME"]
+	}
+	return strings.TrimSpace(values["NAME"] + " " + values["VERSION"])
+}
+
+// windowsProduct returns the edition from the registry, e.g. "Windows 10 Pro"
+func windowsProduct(ctx context.Context) string {
+	out := output(ctx, "reg", "query", `HKLM\SOFTWARE\Microsoft\Windows NT\CurrentVersion`, "/v", "ProductName")
+	// ProductName    REG_SZ    Windows 10 Pro
+	if _, value, ok := strings.Cut(out, "REG_SZ"); ok {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+var windowsVersion = regexp.MustCompile(`\d+\.\d+\.\d+(\.\d+)?`)
+
+// windowsKernel returns the version "ver" prints, e.g. 10.0.19045.5011
+func windowsKernel(ctx context.Context) string {
+	return windowsVersion.FindString(output(ctx, "cmd", "/c", "ver"))
+}
+
+// output runs a command and returns its trimmed standard output, or "" if it fails
+func output(ctx context.Context, name string, args ...string) string {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,200 @@
+// Package jsonfile reads and updates JSON documents shared by goroutines and processes.
+//
+// An update holds an advisory lock on <path>.lock, writes the new document to a temporary
+// file and renames it over the old one, so readers never see a partial document. The last
+// good document is kept in <path>.bak and restored when the file turns out to be corrupt.
+// Documents are sealed with the given key, if any, and readable by the owner only.
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+
+	"dlpagent/internal/seal"
+)
+
+const (
+	lockTimeout    = 30 * time.Second
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// CorruptError is returned when a file cannot be parsed and has no usable backup
+type CorruptError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%s is corrupt and has no usable backup (%v); move it away to start a new history", e.Path, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// BackupPath returns the file the last good version of path is kept in
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// Read decodes the document at path into v under a shared lock. A missing file leaves v
+// unchanged. A corrupt file is read from its backup instead; it is repaired by the next Update.
+func Read(path string, key *seal.Key, v interface{}) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	lock, err := acquire(path, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	_, err = load(path, key, v)
+	return err
+}
+
+// Update decodes the document at path into v (left unchanged when the file does not exist),
+// calls fn to modify v and writes v back atomically, all under an exclusive lock.
+// When fn returns an error nothing is written.
+func Update(path string, key *seal.Key, v interface{}, fn func() error) error {
+	lock, err := acquire(path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	previous, err := load(path, key, v)
+	if err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if previous != nil {
+		if err := write(BackupPath(path), key.Seal(previous)); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+	if err := write(path, key.Seal(data)); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	return nil
+}
+
+// Write replaces the document at path with v under an exclusive lock, without a backup
+func Write(path string, key *seal.Key, v interface{}) error {
+	lock, err := acquire(path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := write(path, key.Seal(data)); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	return nil
+}
+
+// acquire takes the lock of path, waiting up to lockTimeout for other writers
+func acquire(path string, exclusive bool) (*flock.Flock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	lock := flock.New(path + ".lock")
+	var locked bool
+	var err error
+	if exclusive {
+		locked, err = lock.TryLockContext(ctx, lockRetryDelay)
+	} else {
+		locked, err = lock.TryRLockContext(ctx, lockRetryDelay)
+	}
+	if err != nil || !locked {
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// load decodes path into v and returns the plain document it was decoded from, or nil when
+// the file does not exist. A corrupt file is replaced by its backup if that parses, otherwise
+// a *CorruptError is returned. Encrypted files that cannot be decrypted are not corrupt.
+func load(path string, key *seal.Key, v interface{}) ([]byte, error) {
+	data, err := readPlain(path, key)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	parseErr := json.Unmarshal(data, v)
+	if parseErr == nil {
+		return data, nil
+	}
+
+	// Partial writes of older agents and full disks leave truncated files
+	backup, err := readPlain(BackupPath(path), key)
+	if err != nil || json.Unmarshal(backup, v) != nil {
+		return nil, &CorruptError{Path: path, Err: parseErr}
+	}
+	fmt.Printf("Warning: %s is corrupt (%v), using its backup %s\n", path, parseErr, BackupPath(path))
+	return backup, nil
+}
+
+// readPlain reads path and decrypts it if it is sealed
+func readPlain(path string, key *seal.Key) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	plain, err := key.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// write replaces path with data through a synced temporary file in the same directory
+func write(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+
+	"dlpagent/internal/seal"
+)
+
+type counter struct {
+	Count int `json:"count"`
+}
+
+// increment adds one to the count stored at path
+func increment(path string, key *seal.Key) error {
+	var c counter
+	return Update(path, key, &c, func() error {
+		c.Count++
+		return nil
+	})
+}
+
+func TestCorruptFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string // content the file is replaced with after two updates
+		backup    string // content the backup is replaced with, if not empty
+		want      int
+		wantError bool
+	}{
+		{name: "truncated", file: `{"cou`, want: 1},
+		{name: "empty", file: ``, want: 1},
+		{name: "truncated with a corrupt backup", file: `{"cou`, backup: `{`, wantError: true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "results.json")
+		for i := 0; i < 2; i++ {
+			if err := increment(path, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if tt.backup != "" {
+			if err := os.WriteFile(BackupPath(path), []byte(tt.backup), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var c counter
+		err := Read(path, nil, &c)
+		var corrupt *CorruptError
+		if tt.wantError {
+			if !errors.As(err, &corrupt) {
+				t.Errorf("%s: Read() = %v, want a CorruptError", tt.name, err)
+			}
+			if err := increment(path, nil); !errors.As(err, &corrupt) {
+				t.Errorf("%s: Update() = %v, want a CorruptError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || c.Count != tt.want {
+			t.Errorf("%s: Read() = %d, %v, want the backup count %d", tt.name, c.Count, err, tt.want)
+		}
+
+		// The next update continues from the backup and repairs the file
+		if err := increment(path, nil); err != nil {
+			t.Fatalf("%s: Update() = %v", tt.name, err)
+		}
+		c = counter{}
+		if err := Read(path, nil, &c); err != nil || c.Count != tt.want+1 {
+			t.Errorf("%s: Read() after repair = %d, %v, want %d", tt.name, c.Count, err, tt.want+1)
+		}
+	}
+}
+
+func TestUpdateSealed(t *testing.T) {
+	key, err := seal.NewKey([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "results.json")
+	for i := 0; i < 2; i++ {
+		if err := increment(path, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{path, BackupPath(path)} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !seal.IsSealed(data) {
+			t.Errorf("%s is not sealed", filepath.Base(file))
+		}
+	}
+	var c counter
+	if err := Read(path, key, &c); err != nil || c.Count != 2 {
+		t.Errorf("Read() = %d, %v, want 2", c.Count, err)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := increment(path, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var c counter
+	if err := Read(path, nil, &c); err != nil || c.Count != writers {
+		t.Errorf("Read() = %d, %v, want %d", c.Count, err, writers)
+	}
+}
+
+func TestUpdateWaitsForLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	lock := flock.New(path + ".lock")
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- increment(path, nil) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Update() = %v while another writer holds the lock", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	lock.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Update() did not finish after the lock was released")
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-ntlmssp"
+	"github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// authenticator answers the challenges of a proxy on a single connection
+type authenticator interface {
+	// scheme is the auth-scheme of the Proxy-Authenticate challenges it answers, e.g. NTLM
+	scheme() string
+	// legs is the number of requests the handshake takes
+	legs() int
+	// token returns the Proxy-Authorization value for the next leg, given the challenge
+	// data of the proxy to the previous one (nil on the first leg)
+	token(challenge []byte) (string, error)
+}
+
+// authSource creates the authenticator for each connection to a proxy
+type authSource struct {
+	options Options
+
+	mu  sync.Mutex
+	krb *client.Client // logged in on first use of Negotiate
+}
+
+// new returns the authenticator for a connection to p, or nil without authentication.
+// SOCKS5 proxies authenticate in the dialer instead.
+func (s *authSource) new(p *url.URL) (authenticator, error) {
+	switch s.options.auth() {
+	case AuthBasic:
+		return &basicAuth{username: s.options.Username, password: s.options.Password}, nil
+	case AuthNTLM:
+		return &ntlmAuth{username: s.options.Username, password: s.options.Password}, nil
+	case AuthNegotiate:
+		krb, err := s.kerberos()
+		if err != nil {
+			return nil, err
+		}
+		return &negotiateAuth{client: krb, spn: "HTTP/" + p.Hostname()}, nil
+	}
+	return nil, nil
+}
+
+// kerberos logs in with the password, or loads the credential cache
+func (s *authSource) kerberos() (*client.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.krb != nil {
+		return s.krb, nil
+	}
+
+	confPath := os.Getenv("KRB5_CONFIG")
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := krbconfig.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kerberos configuration: %w", err)
+	}
+
+	var krb *client.Client
+	if s.options.Password != "" {
+		user, realm, _ := strings.Cut(s.options.Username, "@")
+		if realm == "" {
+			realm = conf.LibDefaults.DefaultRealm
+		}
+		krb = client.NewWithPassword(user, realm, s.options.Password, conf, client.DisablePAFXFAST(true))
+	} else {
+		cachePath := strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		if cachePath == "" {
+			cachePath = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+		}
+		cache, err := credentials.LoadCCache(cachePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kerberos credential cache: %w", err)
+		}
+		if krb, err = client.NewFromCCache(cache, conf, client.DisablePAFXFAST(true)); err != nil {
+			return nil, fmt.Errorf("failed to use Kerberos credential cache: %w", err)
+		}
+	}
+	if err := krb.AffirmLogin(); err != nil {
+		return nil, fmt.Errorf("Kerberos login failed: %w", err)
+	}
+	s.krb = krb
+	return krb, nil
+}
+
+type basicAuth struct {
+	username, password string
+}
+
+func (a *basicAuth) scheme() string { return "Basic" }
+func (a *basicAuth) legs() int      { return 1 }
+
+func (a *basicAuth) token(challenge []byte) (string, error) {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.username+":"+a.password)), nil
+}
+
+// ntlmAuth sends the negotiate message, then answers the challenge on the same connection
+type ntlmAuth struct {
+	username, password string
+}
+
+func (a *ntlmAuth) scheme() string { return "NTLM" }
+func (a *ntlmAuth) legs() int      { return 2 }
+
+func (a *ntlmAuth) token(challenge []byte) (string, error) {
+	user, domain, domainNeeded := ntlmssp.GetDomain(a.username)
+	var msg []byte
+	var err error
+	if challenge == nil {
+		msg, err = ntlmssp.NewNegotiateMessage(domain, "")
+	} else {
+		msg, err = ntlmssp.ProcessChallenge(challenge, user, a.password, domainNeeded)
+	}
+	if err != nil {
+		return "", fmt.Errorf("NTLM: %w", err)
+	}
+	return "NTLM " + base64.StdEncoding.EncodeToString(msg), nil
+}
+
+// negotiateAuth sends a Kerberos service ticket for HTTP/<proxy host> with SPNEGO
+type negotiateAuth struct {
+	client *client.Client
+	spn    string
+}
+
+func (a *negotiateAuth) scheme() string { return "Negotiate" }
+func (a *negotiateAuth) legs() int      { return 1 }
+
+func (a *negotiateAuth) token(challenge []byte) (string, error) {
+	token, err := spnego.SPNEGOClient(a.client, a.spn).InitSecContext()
+	if err != nil {
+		return "", fmt.Errorf("Negotiate: failed to get a service ticket for %s: %w", a.spn, err)
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("Negotiate: %w", err)
+	}
+	return "Negotiate " + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// challengeFor returns the challenge data the proxy sent for scheme, and whether it offered the scheme
+func challengeFor(header http.Header, scheme string) ([]byte, bool) {
+	for _, value := range header.Values("Proxy-Authenticate") {
+		name, data, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(name, scheme) {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			// Basic carries parameters, not data
+			return nil, true
+		}
+		return decoded, true
+	}
+	return nil, false
+}
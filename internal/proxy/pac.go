@@ -0,0 +1,379 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// pacTimeout bounds each run of the PAC script, so a script that loops forever cannot hang requests
+const pacTimeout = 5 * time.Second
+
+// pacResolver evaluates FindProxyForURL of a PAC file. The file is fetched on first use
+// and again after a failed fetch.
+type pacResolver struct {
+	location string        // PAC URL or path, or DiscoverPAC
+	client   *http.Client  // direct client for fetching the PAC file
+	timeout  time.Duration // limit for each run of the script, pacTimeout if zero
+
+	mu sync.Mutex // goja runtimes are not safe for concurrent use
+	vm *goja.Runtime
+	fn goja.Callable
+}
+
+func (r *pacResolver) proxies(ctx context.Context, u *url.URL) ([]*url.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fn == nil {
+		if err := r.load(ctx); err != nil {
+			return nil, fmt.Errorf("failed to load PAC file: %w", err)
+		}
+	}
+
+	// Like browsers, only the scheme and host of HTTPS URLs are passed to the script
+	target := u.String()
+	if u.Scheme == "https" {
+		target = u.Scheme + "://" + u.Host + "/"
+	}
+	var value goja.Value
+	err := r.run(ctx, r.vm, func() (err error) {
+		value, err = r.fn(goja.Undefined(), r.vm.ToValue(target), r.vm.ToValue(u.Hostname()))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("FindProxyForURL failed: %w", err)
+	}
+	return parsePACResult(value.String())
+}
+
+// load fetches the PAC file and compiles FindProxyForURL
+func (r *pacResolver) load(ctx context.Context) error {
+	script, err := r.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	vm := goja.New()
+	vm.Set("dnsResolve", pacDNSResolve)
+	vm.Set("myIpAddress", pacMyIPAddress)
+	vm.Set("alert", func(msg string) {})
+	if _, err := vm.RunString(pacUtilities); err != nil {
+		return err
+	}
+	err = r.run(ctx, vm, func() error {
+		_, err := vm.RunString(script)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("invalid PAC script: %w", err)
+	}
+	fn, ok := goja.AssertFunction(vm.Get("FindProxyForURL"))
+	if !ok {
+		return fmt.Errorf("PAC script does not define FindProxyForURL")
+	}
+	r.vm, r.fn = vm, fn
+	return nil
+}
+
+// run calls f, interrupting the script in vm when the timeout passes or ctx is done
+func (r *pacResolver) run(ctx context.Context, vm *goja.Runtime, f func() error) error {
+	timeout := r.timeout
+	if timeout == 0 {
+		timeout = pacTimeout
+	}
+	// An interrupt that arrived after the previous run returned would stop this one
+	vm.ClearInterrupt()
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt(fmt.Errorf("PAC script did not finish within %s", timeout))
+	})
+	stop := context.AfterFunc(ctx, func() {
+		vm.Interrupt(ctx.Err())
+	})
+	defer timer.Stop()
+	defer stop()
+	return f()
+}
+
+// fetch reads the PAC file from its URL or path, or discovers it with WPAD
+func (r *pacResolver) fetch(ctx context.Context) (string, error) {
+	if r.location == DiscoverPAC {
+		var errs []string
+		for _, candidate := range wpadURLs() {
+			script, err := r.fetchURL(ctx, candidate)
+			if err == nil {
+				return script, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		if len(errs) == 0 {
+			return "", fmt.Errorf("WPAD: no DNS domain to search")
+		}
+		return "", fmt.Errorf("WPAD: %s", strings.Join(errs, "; "))
+	}
+
+	if strings.HasPrefix(r.location, "http://") || strings.HasPrefix(r.location, "https://") {
+		return r.fetchURL(ctx, r.location)
+	}
+	data, err := os.ReadFile(strings.TrimPrefix(r.location, "file://"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (r *pacResolver) fetchURL(ctx context.Context, location string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: unexpected status: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// wpadURLs returns the WPAD locations to try, from the most to the least specific DNS domain
+func wpadURLs() []string {
+	var domains []string
+	if hostname, err := os.Hostname(); err == nil {
+		if i := strings.IndexByte(hostname, '.'); i > 0 {
+			domains = append(domains, hostname[i+1:])
+		}
+	}
+	if data, err := os.ReadFile("/etc/resolv.conf"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 && (fields[0] == "search" || fields[0] == "domain") {
+				domains = append(domains, fields[1:]...)
+			}
+		}
+	}
+
+	var urls []string
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		labels := strings.Split(strings.Trim(domain, "."), ".")
+		// Never go above the registrable domain, e.g. wpad.com
+		for i := 0; len(labels)-i >= 2; i++ {
+			u := "http://wpad." + strings.Join(labels[i:], ".") + "/wpad.dat"
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// parsePACResult parses a FindProxyForURL result such as "PROXY a:8080; SOCKS b:1080; DIRECT"
+func parsePACResult(result string) ([]*url.URL, error) {
+	var proxies []*url.URL
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := strings.ToUpper(fields[0])
+		if kind == "DIRECT" {
+			proxies = append(proxies, nil)
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid PAC result %q", result)
+		}
+
+		var scheme string
+		switch kind {
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			// SOCKS4 and unknown types are skipped like in browsers
+			continue
+		}
+		proxies = append(proxies, &url.URL{Scheme: scheme, Host: fields[1]})
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("PAC result %q has no usable proxy", result)
+	}
+	return proxies, nil
+}
+
+// pacDNSResolve returns the first IPv4 address of host, or null
+func pacDNSResolve(host string) interface{} {
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ip4 := addr.To4(); ip4 != nil {
+			return ip4.String()
+		}
+	}
+	return nil
+}
+
+// pacMyIPAddress returns the address of the interface used for outgoing traffic
+func pacMyIPAddress() string {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// pacUtilities are the PAC functions that need no access to the host
+const pacUtilities = `
+function isPlainHostName(host) {
+	return host.indexOf('.') < 0;
+}
+
+function dnsDomainIs(host, domain) {
+	return host.length >= domain.length && host.substring(host.length - domain.length) === domain;
+}
+
+function localHostOrDomainIs(host, hostdom) {
+	return host === hostdom || (isPlainHostName(host) && hostdom.indexOf(host + '.') === 0);
+}
+
+function dnsDomainLevels(host) {
+	return host.split('.').length - 1;
+}
+
+function isResolvable(host) {
+	return dnsResolve(host) !== null;
+}
+
+function shExpMatch(str, shexp) {
+	var re = shexp.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+	return new RegExp('^' + re + '$').test(str);
+}
+
+function convert_addr(ip) {
+	var b = ip.split('.');
+	return ((b[0] & 0xff) << 24 | (b[1] & 0xff) << 16 | (b[2] & 0xff) << 8 | (b[3] & 0xff)) >>> 0;
+}
+
+function isInNet(host, pattern, mask) {
+	var ip = /^\d+\.\d+\.\d+\.\d+$/.test(host) ? host : dnsResolve(host);
+	if (ip === null) {
+		return false;
+	}
+	var m = convert_addr(mask);
+	return ((convert_addr(ip) & m) >>> 0) === ((convert_addr(pattern) & m) >>> 0);
+}
+
+var __days = ['SUN', 'MON', 'TUE', 'WED', 'THU', 'FRI', 'SAT'];
+var __months = ['JAN', 'FEB', 'MAR', 'APR', 'MAY', 'JUN', 'JUL', 'AUG', 'SEP', 'OCT', 'NOV', 'DEC'];
+
+// __args returns the arguments without a trailing "GMT" and whether it was given
+function __args(args) {
+	var list = Array.prototype.slice.call(args);
+	var gmt = list.length > 0 && list[list.length - 1] === 'GMT';
+	if (gmt) {
+		list.pop();
+	}
+	return {list: list, gmt: gmt};
+}
+
+function __inRange(start, value, end) {
+	return start <= end ? start <= value && value <= end : value >= start || value <= end;
+}
+
+function weekdayRange() {
+	var a = __args(arguments);
+	var now = new Date();
+	var today = a.gmt ? now.getUTCDay() : now.getDay();
+	var start = __days.indexOf(a.list[0]);
+	var end = a.list.length > 1 ? __days.indexOf(a.list[1]) : start;
+	return start >= 0 && end >= 0 && __inRange(start, today, end);
+}
+
+function timeRange() {
+	var a = __args(arguments);
+	var now = new Date();
+	var h = a.gmt ? now.getUTCHours() : now.getHours();
+	var m = a.gmt ? now.getUTCMinutes() : now.getMinutes();
+	var s = a.gmt ? now.getUTCSeconds() : now.getSeconds();
+	var l = a.list;
+	switch (l.length) {
+	case 1:
+		return h === l[0];
+	case 2:
+		return l[0] <= l[1] ? l[0] <= h && h < l[1] : h >= l[0] || h < l[1];
+	case 4:
+		var start = l[0] * 60 + l[1], end = l[2] * 60 + l[3], cur = h * 60 + m;
+		return start <= end ? start <= cur && cur < end : cur >= start || cur < end;
+	case 6:
+		var start = l[0] * 3600 + l[1] * 60 + l[2], end = l[3] * 3600 + l[4] * 60 + l[5], cur = h * 3600 + m * 60 + s;
+		return start <= end ? start <= cur && cur < end : cur >= start || cur < end;
+	}
+	return false;
+}
+
+// dateRange accepts days (1-31), month names and years, alone or as start and end of a range
+function dateRange() {
+	var a = __args(arguments);
+	var now = new Date();
+	var cur = {
+		day: a.gmt ? now.getUTCDate() : now.getDate(),
+		month: a.gmt ? now.getUTCMonth() : now.getMonth(),
+		year: a.gmt ? now.getUTCFullYear() : now.getFullYear()
+	};
+
+	function parse(values) {
+		var d = {};
+		for (var i = 0; i < values.length; i++) {
+			var v = values[i];
+			if (typeof v === 'string') {
+				d.month = __months.indexOf(v);
+			} else if (v > 31) {
+				d.year = v;
+			} else {
+				d.day = v;
+			}
+		}
+		return d;
+	}
+	function key(d, fields) {
+		return (fields.year ? d.year * 10000 : 0) + (fields.month !== undefined ? d.month * 100 : 0) + (fields.day ? d.day : 0);
+	}
+
+	var l = a.list;
+	if (l.length === 0 || l.length > 6) {
+		return false;
+	}
+	var start, end;
+	if (l.length === 1 || l.length === 3 && typeof l[1] === 'string' && l[0] <= 31 && l[2] > 31) {
+		start = end = parse(l);
+	} else {
+		start = parse(l.slice(0, l.length / 2));
+		end = parse(l.slice(l.length / 2));
+	}
+	var value = key(cur, start), from = key(start, start), to = key(end, start);
+	return start.year ? from <= value && value <= to : __inRange(from, value, to);
+}
+`
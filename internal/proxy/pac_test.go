@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// routes formats proxies the way a PAC result lists them, with DIRECT for nil
+func routes(proxies []*url.URL) string {
+	var parts []string
+	for _, p := range proxies {
+		if p == nil {
+			parts = append(parts, "DIRECT")
+			continue
+		}
+		parts = append(parts, p.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestParsePACResult(t *testing.T) {
+	tests := []struct {
+		result string
+		want   string
+		ok     bool
+	}{
+		{"DIRECT", "DIRECT", true},
+		{"PROXY proxy.corp:8080", "http://proxy.corp:8080", true},
+		{"PROXY a:8080; SOCKS b:1080; DIRECT", "http://a:8080, socks5://b:1080, DIRECT", true},
+		{"HTTPS secure.corp:443;  direct ;", "https://secure.corp:443, DIRECT", true},
+		{"SOCKS4 old:1080; PROXY a:3128", "http://a:3128", true},
+		{"SOCKS4 old:1080", "", false},
+		{"PROXY", "", false},
+		{"PROXY a:1 b:2", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		proxies, err := parsePACResult(tt.result)
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePACResult(%q) error = %v, want ok %v", tt.result, err, tt.ok)
+			continue
+		}
+		if got := routes(proxies); got != tt.want {
+			t.Errorf("parsePACResult(%q) = %s, want %s", tt.result, got, tt.want)
+		}
+	}
+}
+
+const testPAC = `function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || dnsDomainIs(host, ".corp.example")) return "DIRECT";
+	if (shExpMatch(host, "*.eicar.org")) return "PROXY av-gateway:8080";
+	if (/^[0-9.]+$/.test(host) && isInNet(host, "10.0.0.0", "255.0.0.0")) return "SOCKS5 socks:1080";
+	if (url.indexOf("/secret") >= 0) return "PROXY path-leak:1";
+	return "PROXY proxy:3128; DIRECT";
+}`
+
+func TestPACResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	if err := os.WriteFile(path, []byte(testPAC), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := &pacResolver{location: path}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://intranet/", "DIRECT"},
+		{"http://wiki.corp.example/page", "DIRECT"},
+		{"https://secure.eicar.org/download/eicar.com", "http://av-gateway:8080"},
+		{"http://10.1.2.3/upload", "socks5://socks:1080"},
+		{"http://dlp.example/secret", "http://path-leak:1"},
+		// Only the scheme and host of HTTPS URLs are passed to the script
+		{"https://dlp.example/secret", "http://proxy:3128, DIRECT"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxies, err := r.proxies(context.Background(), u)
+		if err != nil {
+			t.Fatalf("proxies(%s) = %v", tt.url, err)
+		}
+		if got := routes(proxies); got != tt.want {
+			t.Errorf("proxies(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPACResolverInvalidScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"syntax error", "function FindProxyForURL(url, host) {"},
+		{"no FindProxyForURL", `function findProxy(url, host) { return "DIRECT"; }`},
+		{"throws", `function FindProxyForURL(url, host) { throw new Error("boom"); }`},
+		{"unusable result", `function FindProxyForURL(url, host) { return "SOCKS4 old:1080"; }`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "proxy.pac")
+		if err := os.WriteFile(path, []byte(tt.script), 0600); err != nil {
+			t.Fatal(err)
+		}
+		r := &pacResolver{location: path}
+		if _, err := r.proxies(context.Background(), &url.URL{Scheme: "http", Host: "dlp.example"}); err == nil {
+			t.Errorf("%s: proxies() = nil error", tt.name)
+		}
+	}
+}
+
+func TestPACResolverInterrupt(t *testing.T) {
+	const looping = `function FindProxyForURL(url, host) {
+	while (host == "loop.example") {}
+	return "DIRECT";
+}`
+	tests := []struct {
+		name    string
+		script  string
+		host    string
+		timeout time.Duration
+		cancel  bool
+	}{
+		{"loop while loading", "while (true) {}", "dlp.example", 100 * time.Millisecond, false},
+		{"loop in FindProxyForURL", looping, "loop.example", 100 * time.Millisecond, false},
+		{"cancelled while loading", "while (true) {}", "dlp.example", time.Minute, true},
+		{"cancelled in FindProxyForURL", looping, "loop.example", time.Minute, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "proxy.pac")
+		if err := os.WriteFile(path, []byte(tt.script), 0600); err != nil {
+			t.Fatal(err)
+		}
+		r := &pacResolver{location: path, timeout: tt.timeout}
+		ctx := context.Background()
+		if tt.cancel {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+		}
+		start := time.Now()
+		if _, err := r.proxies(ctx, &url.URL{Scheme: "http", Host: tt.host}); err == nil {
+			t.Errorf("%s: proxies() = nil error", tt.name)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: proxies() took %s", tt.name, elapsed)
+		}
+		// The runtime stays usable after an interrupted call
+		if r.fn != nil {
+			proxies, err := r.proxies(context.Background(), &url.URL{Scheme: "http", Host: "dlp.example"})
+			if err != nil || routes(proxies) != "DIRECT" {
+				t.Errorf("%s: proxies() after the interrupt = %s, %v, want DIRECT", tt.name, routes(proxies), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package proxy sends test traffic through explicit proxies, chosen by a fixed URL, the
+// environment or a PAC file, and authenticates to them with Basic, NTLM or Negotiate.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	// FromEnvironment as the proxy URL uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	FromEnvironment = "env"
+	// DiscoverPAC as the PAC location finds the PAC file with WPAD
+	DiscoverPAC = "wpad"
+)
+
+// Proxy authentication schemes
+const (
+	AuthNone      = "none"
+	AuthBasic     = "basic"
+	AuthNTLM      = "ntlm"
+	AuthNegotiate = "negotiate"
+)
+
+// Options select the proxy for test traffic and how to authenticate to it
+type Options struct {
+	URL      string // http://, https:// or socks5:// proxy URL, or FromEnvironment
+	PAC      string // PAC file URL or path, or DiscoverPAC; takes precedence over URL
+	Auth     string // AuthNone, AuthBasic, AuthNTLM or AuthNegotiate; default basic with credentials, none without
+	Username string // DOMAIN\user for NTLM, user@REALM for Negotiate
+	Password string // optional for Negotiate, which otherwise uses the Kerberos credential cache
+}
+
+// Enabled reports whether a proxy is configured
+func (o Options) Enabled() bool {
+	return o.URL != "" || o.PAC != ""
+}
+
+// Validate checks the proxy URL and authentication settings
+func (o Options) Validate() error {
+	if o.URL != "" && o.URL != FromEnvironment {
+		if _, err := parseProxyURL(o.URL); err != nil {
+			return err
+		}
+	}
+	if o.PAC != "" && o.PAC != DiscoverPAC && strings.Contains(o.PAC, "://") {
+		if u, err := url.Parse(o.PAC); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+			return fmt.Errorf("PAC location must be an http(s) or file URL, a path or %q, got %q", DiscoverPAC, o.PAC)
+		}
+	}
+
+	switch o.auth() {
+	case AuthNone:
+	case AuthBasic, AuthNTLM:
+		if o.Username == "" {
+			return fmt.Errorf("%s proxy authentication requires a username", o.auth())
+		}
+	case AuthNegotiate:
+	default:
+		return fmt.Errorf("unknown proxy authentication %q (expected none, basic, ntlm or negotiate)", o.Auth)
+	}
+	return nil
+}
+
+// auth returns the authentication scheme, defaulting to basic when a username is set
+func (o Options) auth() string {
+	if o.Auth != "" {
+		return strings.ToLower(o.Auth)
+	}
+	if o.Username != "" {
+		return AuthBasic
+	}
+	return AuthNone
+}
+
+// parseProxyURL parses a proxy URL; a bare host:port is an HTTP proxy
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (expected http, https or socks5)", u.Scheme)
+	}
+	return u, nil
+}
+
+// resolver chooses the proxies for a request
+type resolver interface {
+	// proxies returns the proxies to try for u in order; a nil entry is a direct connection
+	proxies(ctx context.Context, u *url.URL) ([]*url.URL, error)
+}
+
+// fixedResolver sends every request through the same proxy
+type fixedResolver struct {
+	proxy *url.URL
+}
+
+func (r fixedResolver) proxies(ctx context.Context, u *url.URL) ([]*url.URL, error) {
+	return []*url.URL{r.proxy}, nil
+}
+
+// envResolver uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY as read at startup
+type envResolver struct {
+	proxyFunc func(*url.URL) (*url.URL, error)
+}
+
+func (r envResolver) proxies(ctx context.Context, u *url.URL) ([]*url.URL, error) {
+	p, err := r.proxyFunc(u)
+	if err != nil {
+		return nil, err
+	}
+	return []*url.URL{p}, nil
+}
+
+func newEnvResolver() envResolver {
+	return envResolver{proxyFunc: httpproxy.FromEnvironment().ProxyFunc()}
+}
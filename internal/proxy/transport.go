@@ -0,0 +1,434 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	xproxy "golang.org/x/net/proxy"
+)
+
+// Transport sends each request through the proxies chosen for it, falling back to the
+// next one when a proxy cannot be reached. HTTP proxies forward plain HTTP requests;
+// HTTPS requests and all traffic through SOCKS5 proxies are tunnelled. Connections are
+// not reused, so every request takes its own route and authenticates on its own connection.
+type Transport struct {
+	resolver resolver
+	auth     *authSource
+	dialer   *net.Dialer
+
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+
+	direct *http.Transport // requests without a proxy
+	tunnel *http.Transport // requests over a tunnel established by RoundTrip
+}
+
+// New returns a Transport for opts. base provides the TLS settings and timeouts, dialer
+// connects to proxies and to direct targets. Credentials in the proxy URL are used when
+// opts has no username.
+func New(base *http.Transport, dialer *net.Dialer, opts Options) (*Transport, error) {
+	var fixed *url.URL
+	if opts.PAC == "" && opts.URL != FromEnvironment {
+		u, err := parseProxyURL(opts.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u.User != nil && opts.Username == "" {
+			opts.Username = u.User.Username()
+			opts.Password, _ = u.User.Password()
+		}
+		u.User = nil
+		fixed = u
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := &Transport{
+		auth:                  &authSource{options: opts},
+		dialer:                dialer,
+		tlsHandshakeTimeout:   base.TLSHandshakeTimeout,
+		responseHeaderTimeout: base.ResponseHeaderTimeout,
+	}
+
+	t.direct = base.Clone()
+	t.direct.Proxy = nil
+	t.direct.DialContext = dialer.DialContext
+
+	t.tunnel = base.Clone()
+	t.tunnel.Proxy = nil
+	t.tunnel.DisableKeepAlives = true
+	t.tunnel.DialContext = dialTunnel
+
+	switch {
+	case opts.PAC != "":
+		t.resolver = &pacResolver{location: opts.PAC, client: &http.Client{Transport: t.direct, Timeout: time.Minute}}
+	case opts.URL == FromEnvironment:
+		t.resolver = newEnvResolver()
+	default:
+		t.resolver = fixedResolver{proxy: fixed}
+	}
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxies, err := t.resolver.proxies(req.Context(), req.URL)
+	if err != nil {
+		closeBody(req)
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+
+	// A proxy found unreachable may have read the body already, so the next one is sent a copy
+	if len(proxies) > 1 {
+		if req, err = rewindable(req); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, p := range proxies {
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		resp, err := t.roundTrip(req, p)
+		var unreachable *unreachableError
+		if err == nil || !errors.As(err, &unreachable) || i == len(proxies)-1 || req.Context().Err() != nil {
+			if err != nil {
+				closeBody(req)
+			}
+			return resp, err
+		}
+	}
+	return nil, fmt.Errorf("proxy: no route to %s", req.URL.Host)
+}
+
+// roundTrip sends req through p, or directly when p is nil
+func (t *Transport) roundTrip(req *http.Request, p *url.URL) (*http.Response, error) {
+	if p == nil {
+		return t.direct.RoundTrip(req)
+	}
+	if req.URL.Scheme == "http" && (p.Scheme == "http" || p.Scheme == "https") {
+		return t.forward(req, p)
+	}
+
+	conn, resp, err := t.connect(req.Context(), p, targetAddr(req.URL))
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		// The proxy refused the tunnel, e.g. with a block page; that is the answer to req
+		closeBody(req)
+		resp.Request = req
+		return resp, nil
+	}
+
+	tunnel := &tunnelConn{conn: conn}
+	defer tunnel.close()
+	return t.tunnel.RoundTrip(req.WithContext(context.WithValue(req.Context(), tunnelKey{}, tunnel)))
+}
+
+// forward sends a plain HTTP request to an HTTP proxy on a new connection, answering
+// authentication challenges on the same connection
+func (t *Transport) forward(req *http.Request, p *url.URL) (*http.Response, error) {
+	ctx := req.Context()
+
+	// Every leg of the authentication handshake carries the body
+	req, err := rewindable(req)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := t.dialProxy(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	leg := 0
+	resp, err := t.handshake(conn, bufio.NewReader(conn), p, req, func(header http.Header) error {
+		out := req.Clone(ctx)
+		if leg > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			out.Body = body
+		}
+		leg++
+		for key, values := range header {
+			out.Header[key] = values
+		}
+		return out.WriteProxy(conn)
+	})
+	if err != nil {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	resp.Request = req
+	resp.Body = &connBody{ReadCloser: resp.Body, conn: conn, stop: stop}
+	return resp, nil
+}
+
+// connect opens a tunnel to addr through p. If an HTTP proxy refuses the tunnel its
+// response is returned instead.
+func (t *Transport) connect(ctx context.Context, p *url.URL, addr string) (net.Conn, *http.Response, error) {
+	if p.Scheme == "socks5" || p.Scheme == "socks5h" {
+		var auth *xproxy.Auth
+		if t.auth.options.Username != "" {
+			auth = &xproxy.Auth{User: t.auth.options.Username, Password: t.auth.options.Password}
+		}
+		socks, err := xproxy.SOCKS5("tcp", hostPort(p), auth, proxyDialer{t})
+		if err != nil {
+			return nil, nil, err
+		}
+		conn, err := socks.(xproxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		return conn, nil, err
+	}
+
+	conn, err := t.dialProxy(ctx, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	connectReq := &http.Request{Method: "CONNECT", URL: &url.URL{Opaque: addr}, Host: addr}
+	br := bufio.NewReader(conn)
+	resp, err := t.handshake(conn, br, p, connectReq, func(header http.Header) error {
+		connectReq.Header = header
+		return connectReq.Write(conn)
+	})
+	if err != nil {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body = &connBody{ReadCloser: resp.Body, conn: conn, stop: stop}
+		return nil, resp, nil
+	}
+	if !stop() {
+		conn.Close()
+		return nil, nil, ctx.Err()
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("proxy %s sent data before the tunnel was established", p.Host)
+	}
+	return conn, nil, nil
+}
+
+// handshake sends a request to the proxy with write and reads the response, answering
+// authentication challenges on the same connection
+func (t *Transport) handshake(conn net.Conn, br *bufio.Reader, p *url.URL, req *http.Request, write func(http.Header) error) (*http.Response, error) {
+	auth, err := t.auth.new(p)
+	if err != nil {
+		return nil, err
+	}
+	legs := 1
+	if auth != nil {
+		legs = auth.legs()
+	}
+
+	var challenge []byte
+	for leg := 0; ; leg++ {
+		header := http.Header{}
+		if auth != nil {
+			value, err := auth.token(challenge)
+			if err != nil {
+				return nil, err
+			}
+			header.Set("Proxy-Authorization", value)
+		}
+		if err := write(header); err != nil {
+			return nil, err
+		}
+
+		if t.responseHeaderTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(t.responseHeaderTimeout))
+		}
+		resp, err := http.ReadResponse(br, req)
+		conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusProxyAuthRequired || leg+1 >= legs {
+			return resp, nil
+		}
+		data, ok := challengeFor(resp.Header, auth.scheme())
+		if !ok || resp.Close {
+			return resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		challenge = data
+	}
+}
+
+// dialProxy connects to an HTTP or HTTPS proxy
+func (t *Transport) dialProxy(ctx context.Context, p *url.URL) (net.Conn, error) {
+	conn, err := proxyDialer{t}.DialContext(ctx, "tcp", hostPort(p))
+	if err != nil || p.Scheme != "https" {
+		return conn, err
+	}
+
+	if t.tlsHandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.tlsHandshakeTimeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: p.Hostname(), MinVersion: tls.VersionTLS12})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, &unreachableError{proxy: p.Host, err: err}
+	}
+	return tlsConn, nil
+}
+
+// proxyDialer connects to proxies, marking failures as unreachable
+type proxyDialer struct {
+	t *Transport
+}
+
+func (d proxyDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.t.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &unreachableError{proxy: addr, err: err}
+	}
+	return conn, nil
+}
+
+// unreachableError is a failure to connect to a proxy, after which the next proxy is tried
+type unreachableError struct {
+	proxy string
+	err   error
+}
+
+func (e *unreachableError) Error() string {
+	return fmt.Sprintf("proxy %s unreachable: %v", e.proxy, e.err)
+}
+
+func (e *unreachableError) Unwrap() error { return e.err }
+
+// tunnelKey is the context key of the tunnelConn the tunnel transport dials
+type tunnelKey struct{}
+
+// tunnelConn hands an established tunnel to the tunnel transport once
+type tunnelConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *tunnelConn) take() (net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, errors.New("proxy tunnel already used")
+	}
+	conn := c.conn
+	c.conn = nil
+	return conn, nil
+}
+
+// close closes the tunnel if the transport did not use it
+func (c *tunnelConn) close() {
+	if conn, err := c.take(); err == nil {
+		conn.Close()
+	}
+}
+
+func dialTunnel(ctx context.Context, network, addr string) (net.Conn, error) {
+	tunnel, ok := ctx.Value(tunnelKey{}).(*tunnelConn)
+	if !ok {
+		return nil, fmt.Errorf("no proxy tunnel to %s", addr)
+	}
+	return tunnel.take()
+}
+
+// connBody closes the proxy connection with the response body
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *connBody) Close() error {
+	b.stop()
+	b.ReadCloser.Close()
+	return b.conn.Close()
+}
+
+// targetAddr returns host:port of a request URL
+func targetAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// hostPort returns host:port of a proxy URL with the default port of its scheme
+func hostPort(p *url.URL) string {
+	if p.Port() != "" {
+		return p.Host
+	}
+	switch p.Scheme {
+	case "https":
+		return net.JoinHostPort(p.Hostname(), "443")
+	case "socks5", "socks5h":
+		return net.JoinHostPort(p.Hostname(), "1080")
+	}
+	return net.JoinHostPort(p.Hostname(), "80")
+}
+
+// rewindable returns req with a GetBody that returns its body again, reading the body into
+// memory if req has none
+func rewindable(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return req, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	return req, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFallbackKeepsBody(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer target.Close()
+
+	// A proxy port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := l.Addr().String()
+	l.Close()
+
+	pac := filepath.Join(t.TempDir(), "proxy.pac")
+	script := fmt.Sprintf(`function FindProxyForURL(url, host) { return "PROXY %s; DIRECT"; }`, unreachable)
+	if err := os.WriteFile(pac, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	transport, err := New(http.DefaultTransport.(*http.Transport), &net.Dialer{}, Options{PAC: pac})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A body without GetBody, like a streamed upload
+	const body = "4111 1111 1111 1111"
+	req, err := http.NewRequest(http.MethodPost, target.URL, io.NopCloser(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() = %v", err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if string(got) != body {
+		t.Errorf("body received directly = %q, want %q", got, body)
+	}
+}
@@ -0,0 +1,54 @@
+package ransomware
+
+import "time"
+
+type Result struct {
+	Verdict        Verdict
+	StatusText     string
+	SandboxPath    string        // sandbox directory the simulation ran in
+	FilesCreated   int           // dummy files generated in the sandbox
+	FilesEncrypted int           // files renamed and overwritten before the worker stopped
+	FilesRestored  int           // files found back under their original name and content
+	ProcessKilled  bool          // whether the worker process was terminated externally
+	ExitCode       int           // exit code of the worker process, -1 if it was killed by a signal
+	Signal         string        // signal that killed the worker process
+	Duration       time.Duration // time the worker process ran
+	IP             string        // IP address of the computer running the check
+}
+
+// CheckResultEntry represents a single result entry stored in JSON
+type CheckResultEntry struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Verdict        Verdict   `json:"verdict"`
+	StatusText     string    `json:"status_text"`
+	IsEDRActive    bool      `json:"is_edr_active"`
+	ProcessKilled  bool      `json:"process_killed"`
+	FilesCreated   int       `json:"files_created"`
+	FilesEncrypted int       `json:"files_encrypted"`
+	FilesRestored  int       `json:"files_restored"`
+	ExitCode       int       `json:"exit_code"`
+	DurationMs     int64     `json:"duration_ms"`
+	IP             string    `json:"ip"`
+}
+
+// CheckResultsHistory stores the history of check results
+type CheckResultsHistory struct {
+	Results []CheckResultEntry `json:"results"`
+}
+
+// Options controls the ransomware simulation
+type Options struct {
+	BaseDir       string        // directory the sandbox is created in (default: system temp dir)
+	FileCount     int           // number of dummy files to generate
+	RollbackDelay time.Duration // time to wait for the EDR to roll files back
+	Timeout       time.Duration // maximum run time of the worker process
+}
+
+// DefaultOptions returns the options used when none are given
+func DefaultOptions() Options {
+	return Options{
+		FileCount:     50,
+		RollbackDelay: 10 * time.Second,
+		Timeout:       2 * time.Minute,
+	}
+}
@@ -0,0 +1,185 @@
+package ransomware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"dlpagent/internal/host"
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/seal"
+)
+
+type Orchestrator struct {
+	options Options
+}
+
+func NewOrchestrator(options Options) *Orchestrator {
+	defaults := DefaultOptions()
+	if options.FileCount <= 0 {
+		options.FileCount = defaults.FileCount
+	}
+	if options.RollbackDelay <= 0 {
+		options.RollbackDelay = defaults.RollbackDelay
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	return &Orchestrator{options: options}
+}
+
+// RunRansomwareCheck generates a sandbox of dummy files, lets a re-executed copy of the
+// agent behave like ransomware inside it and measures whether the EDR kills the worker
+// or rolls the files back. The sandbox is removed afterwards. When ctx is done the
+// worker is stopped and the result is an error, as the EDR was not measured.
+func (o *Orchestrator) RunRansomwareCheck(ctx context.Context) *Result {
+	box, err := newSandbox(o.options.BaseDir)
+	if err != nil {
+		return &Result{Verdict: VerdictError, StatusText: err.Error(), IP: host.PrimaryIP()}
+	}
+	defer box.remove()
+
+	result := &Result{SandboxPath: box.root, IP: host.PrimaryIP()}
+
+	originals, err := box.populate(o.options.FileCount)
+	if err != nil {
+		result.Verdict = VerdictError
+		result.StatusText = err.Error()
+		return result
+	}
+	result.FilesCreated = len(originals)
+
+	if err := o.runWorkerProcess(ctx, box, result); err != nil {
+		result.Verdict = VerdictError
+		result.StatusText = err.Error()
+		return result
+	}
+
+	// Give the EDR time to roll the files back
+	select {
+	case <-time.After(o.options.RollbackDelay):
+	case <-ctx.Done():
+		result.Verdict = VerdictError
+		result.StatusText = "simulation interrupted: " + ctx.Err().Error()
+		return result
+	}
+
+	restored, renamed := box.inspect(originals)
+	result.FilesRestored = restored
+	if result.FilesEncrypted == 0 {
+		result.FilesEncrypted = renamed
+	}
+
+	return EvaluateResult(result)
+}
+
+// runWorkerProcess re-executes the agent as the simulation worker and records how it ended
+func (o *Orchestrator) runWorkerProcess(parent context.Context, box *sandbox, result *Result) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate agent executable: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, o.options.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Env = append(os.Environ(), WorkerEnv+"="+box.root)
+	cmd.Dir = box.root
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture worker output: %w", err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start worker: %w", err)
+	}
+
+	// Count progress lines as they arrive, so it is known even if the worker is killed
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "encrypted ") {
+			result.FilesEncrypted++
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	waitErr := cmd.Wait()
+	result.Duration = time.Since(start)
+	result.ExitCode = cmd.ProcessState.ExitCode()
+
+	// A worker stopped by the agent itself was not killed by the EDR
+	if parent.Err() != nil {
+		return fmt.Errorf("simulation interrupted: %w", parent.Err())
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("worker timed out after %s", o.options.Timeout)
+	}
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return fmt.Errorf("failed to wait for worker: %w", waitErr)
+	}
+
+	// Only a worker terminated by a signal was killed. Any other exit code means the worker
+	// failed or crashed on its own, which says nothing about the EDR.
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result.ProcessKilled = true
+		result.Signal = status.Signal().String()
+		return nil
+	}
+	switch result.ExitCode {
+	case 0:
+		result.ProcessKilled = false
+	case workerErrorExitCode:
+		return fmt.Errorf("worker failed: %s", strings.TrimSpace(stderr.String()))
+	default:
+		return fmt.Errorf("worker exited with code %d: %s", result.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// NewCheckResultEntry returns the stored form of a result
+func NewCheckResultEntry(result *Result) CheckResultEntry {
+	return CheckResultEntry{
+		Timestamp:      time.Now(),
+		Verdict:        result.Verdict,
+		StatusText:     result.StatusText,
+		IsEDRActive:    result.IsEDRActive(),
+		ProcessKilled:  result.ProcessKilled,
+		FilesCreated:   result.FilesCreated,
+		FilesEncrypted: result.FilesEncrypted,
+		FilesRestored:  result.FilesRestored,
+		ExitCode:       result.ExitCode,
+		DurationMs:     result.Duration.Milliseconds(),
+		IP:             result.IP,
+	}
+}
+
+// SaveResultToJSON saves the entry to JSON file, keeping only last 15 entries
+func (o *Orchestrator) SaveResultToJSON(entry CheckResultEntry, jsonFilePath string, key *seal.Key) error {
+	history := &CheckResultsHistory{
+		Results: []CheckResultEntry{},
+	}
+	return jsonfile.Update(jsonFilePath, key, history, func() error {
+		history.Results = append(history.Results, entry)
+
+		// Keep only last 15 entries
+		if len(history.Results) > 15 {
+			history.Results = history.Results[len(history.Results)-15:]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package ransomware
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testWorkerEnv tells the re-executed test binary how the worker should end
+const testWorkerEnv = "DLPAGENT_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(WorkerEnv) != "" {
+		switch os.Getenv(testWorkerEnv) {
+		case "killed":
+			p, _ := os.FindProcess(os.Getpid())
+			p.Kill()
+			select {}
+		case "crashed":
+			fmt.Fprintln(os.Stderr, "panic: test crash")
+			os.Exit(2)
+		case "failed":
+			fmt.Fprintln(os.Stderr, "sandbox marker missing")
+			os.Exit(workerErrorExitCode)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunWorkerProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("workers are not killed by signals on Windows")
+	}
+	tests := []struct {
+		worker  string
+		killed  bool
+		wantErr string
+	}{
+		{"finished", false, ""},
+		{"killed", true, ""},
+		{"crashed", false, "panic: test crash"},
+		{"failed", false, "sandbox marker missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.worker, func(t *testing.T) {
+			t.Setenv(testWorkerEnv, tt.worker)
+			box, err := newSandbox(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer box.remove()
+
+			result := &Result{}
+			err = NewOrchestrator(Options{}).runWorkerProcess(context.Background(), box, result)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runWorkerProcess() = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("runWorkerProcess() = %v, want an error with %q", err, tt.wantErr)
+			}
+			if result.ProcessKilled != tt.killed {
+				t.Errorf("ProcessKilled = %v, want %v", result.ProcessKilled, tt.killed)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package ransomware
+
+import "fmt"
+
+// Verdict is the outcome of a ransomware simulation
+type Verdict string
+
+const (
+	VerdictProcessKilled Verdict = "process_killed" // the EDR terminated the worker process
+	VerdictRolledBack    Verdict = "rolled_back"    // the worker finished but the EDR restored the files
+	VerdictNotStopped    Verdict = "not_stopped"    // the worker finished and the files stayed encrypted
+	VerdictError         Verdict = "error"          // the simulation could not be run
+)
+
+// IsEDRActive reports whether the EDR stopped the simulation
+func (r *Result) IsEDRActive() bool {
+	return r.Verdict == VerdictProcessKilled || r.Verdict == VerdictRolledBack
+}
+
+func EvaluateResult(result *Result) *Result {
+	switch {
+	case result.ProcessKilled:
+		result.Verdict = VerdictProcessKilled
+		result.StatusText = fmt.Sprintf("Worker process killed (%s) after encrypting %d/%d files, %d restored",
+			result.Signal, result.FilesEncrypted, result.FilesCreated, result.FilesRestored)
+	case result.FilesCreated > 0 && result.FilesRestored == result.FilesCreated:
+		result.Verdict = VerdictRolledBack
+		result.StatusText = fmt.Sprintf("All %d files rolled back after simulation", result.FilesCreated)
+	default:
+		result.Verdict = VerdictNotStopped
+		result.StatusText = fmt.Sprintf("Simulation completed: %d/%d files encrypted, %d restored",
+			result.FilesEncrypted, result.FilesCreated, result.FilesRestored)
+	}
+	return result
+}
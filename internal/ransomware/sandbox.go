@@ -0,0 +1,179 @@
+package ransomware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sandboxPrefix is the name prefix of every sandbox directory
+	sandboxPrefix = "dlpagent-ransomware-"
+
+	// markerFile proves a directory is an agent sandbox; the worker refuses to run without it
+	markerFile = ".dlpagent-sandbox"
+
+	// dummyPrefix is the name prefix of every generated dummy file
+	dummyPrefix = "canary_"
+
+	// ransomNoteName is the ransom-note-like file dropped by the worker
+	ransomNoteName = "README_RESTORE_FILES.txt"
+)
+
+var dummyExtensions = []string{".docx", ".xlsx", ".pdf", ".txt", ".csv", ".jpg"}
+
+// sandbox is a directory of agent-generated dummy files. All file operations of the
+// simulation go through it so nothing outside the directory can be touched.
+type sandbox struct {
+	root string
+}
+
+// newSandbox creates an empty sandbox directory with its marker file
+func newSandbox(baseDir string) (*sandbox, error) {
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+
+	root, err := os.MkdirTemp(baseDir, sandboxPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sandbox path: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, markerFile), []byte("dlpagent ransomware simulation sandbox\n"), 0600); err != nil {
+		os.RemoveAll(root)
+		return nil, fmt.Errorf("failed to write sandbox marker: %w", err)
+	}
+
+	return &sandbox{root: root}, nil
+}
+
+// openSandbox opens an existing sandbox, refusing any directory that is not one
+func openSandbox(root string) (*sandbox, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sandbox path: %w", err)
+	}
+
+	if !strings.HasPrefix(filepath.Base(root), sandboxPrefix) {
+		return nil, fmt.Errorf("refusing to use %s: not a sandbox directory", root)
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat sandbox: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("refusing to use %s: not a directory", root)
+	}
+
+	if _, err := os.Lstat(filepath.Join(root, markerFile)); err != nil {
+		return nil, fmt.Errorf("refusing to use %s: sandbox marker missing", root)
+	}
+
+	return &sandbox{root: root}, nil
+}
+
+// path returns the path of a file directly inside the sandbox and rejects anything else
+func (s *sandbox) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("refusing to access %q outside the sandbox", name)
+	}
+	return filepath.Join(s.root, name), nil
+}
+
+// populate writes count dummy files and returns the SHA-256 of each one by file name
+func (s *sandbox) populate(count int) (map[string][32]byte, error) {
+	originals := make(map[string][32]byte, count)
+
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%s%03d%s", dummyPrefix, i, dummyExtensions[i%len(dummyExtensions)])
+		path, err := s.path(name)
+		if err != nil {
+			return nil, err
+		}
+
+		content := dummyContent(i)
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write dummy file: %w", err)
+		}
+		originals[name] = sha256.Sum256(content)
+	}
+
+	return originals, nil
+}
+
+// dummyFiles lists the regular dummy files currently in the sandbox, including renamed ones
+func (s *sandbox) dummyFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sandbox: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), dummyPrefix) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// inspect counts how many original files are back in place with their original content,
+// and how many files carry an extension added by the worker
+func (s *sandbox) inspect(originals map[string][32]byte) (restored, encrypted int) {
+	names, err := s.dummyFiles()
+	if err != nil {
+		return 0, 0
+	}
+
+	for _, name := range names {
+		sum, ok := originals[name]
+		if !ok {
+			encrypted++
+			continue
+		}
+
+		path, err := s.path(name)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && sha256.Sum256(data) == sum {
+			restored++
+		}
+	}
+	return restored, encrypted
+}
+
+// remove deletes the sandbox directory
+func (s *sandbox) remove() error {
+	if _, err := openSandbox(s.root); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.root)
+}
+
+// dummyContent returns low-entropy, document-like text for a dummy file
+func dummyContent(i int) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Canary document %d\n\n", i)
+	words := []string{"quarterly", "report", "invoice", "customer", "budget", "project", "meeting", "summary"}
+	r := rand.New(rand.NewSource(int64(i)))
+	for line := 0; line < 40; line++ {
+		for w := 0; w < 12; w++ {
+			b.WriteString(words[r.Intn(len(words))])
+			b.WriteByte(' ')
+		}
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
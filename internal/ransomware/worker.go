@@ -0,0 +1,102 @@
+package ransomware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+)
+
+// WorkerEnv is set to the sandbox path when the agent is re-executed as the simulation worker
+const WorkerEnv = "DLPAGENT_RANSOMWARE_SANDBOX"
+
+// workerErrorExitCode is returned by the worker when it could not run the simulation,
+// so it is not mistaken for the process being killed
+const workerErrorExitCode = 3
+
+// RunWorkerIfRequested runs the simulation worker and exits when the process was started
+// as one. It must be called at the very start of main, before flags are parsed.
+func RunWorkerIfRequested() {
+	root := os.Getenv(WorkerEnv)
+	if root == "" {
+		return
+	}
+
+	if err := runWorker(root); err != nil {
+		fmt.Fprintf(os.Stderr, "ransomware worker: %v\n", err)
+		os.Exit(workerErrorExitCode)
+	}
+	os.Exit(0)
+}
+
+// runWorker behaves like ransomware inside the sandbox: it overwrites every dummy file with
+// high-entropy data, renames it with a random extension and drops a ransom note.
+// Each encrypted file is reported on stdout so progress is known even if the process is killed.
+func runWorker(root string) error {
+	box, err := openSandbox(root)
+	if err != nil {
+		return err
+	}
+
+	names, err := box.dummyFiles()
+	if err != nil {
+		return err
+	}
+
+	ext := "." + randomExtension()
+	for _, name := range names {
+		if err := encryptFile(box, name, ext); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "encrypted %s\n", name)
+	}
+
+	notePath, err := box.path(ransomNoteName)
+	if err != nil {
+		return err
+	}
+	note := "Your files have been encrypted.\n" +
+		"This is a harmless simulation run by the DLP agent to test EDR behavioural protection.\n"
+	return os.WriteFile(notePath, []byte(note), 0600)
+}
+
+func encryptFile(box *sandbox, name, ext string) error {
+	path, err := box.path(name)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("refusing to touch non-regular file %s", name)
+	}
+
+	noise := make([]byte, info.Size())
+	if _, err := rand.Read(noise); err != nil {
+		return fmt.Errorf("failed to generate data: %w", err)
+	}
+	if err := os.WriteFile(path, noise, 0600); err != nil {
+		return fmt.Errorf("failed to overwrite %s: %w", name, err)
+	}
+
+	target, err := box.path(name + ext)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", name, err)
+	}
+	return nil
+}
+
+// randomExtension returns five random lowercase letters
+func randomExtension() string {
+	buf := make([]byte, 5)
+	rand.Read(buf)
+	for i := range buf {
+		buf[i] = 'a' + buf[i]%26
+	}
+	return string(buf)
+}
@@ -0,0 +1,138 @@
+// Package redact controls how the content of test files is kept in stored and uploaded results,
+// so the sensitive test data does not end up in plain text on disk, on the dashboard, or in
+// front of the DLP the agent is testing.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content handling modes
+const (
+	Full   = "full"   // content as sent
+	Masked = "masked" // sensitive values masked by the rules of the data category
+	Hash   = "hash"   // SHA-256 digest only
+	Omit   = "omit"   // no content
+)
+
+// Modes lists the content handling modes
+var Modes = []string{Full, Masked, Hash, Omit}
+
+// Data categories with their own masking rules. The DLP categories match the file categories
+// of the DLP results.
+const (
+	CategoryCreditCard = "credit_card"
+	CategoryPassport   = "passport_number"
+	CategoryMalware    = "malware" // antivirus test files, e.g. EICAR
+)
+
+// Validate checks that mode is a content handling mode
+func Validate(mode string) error {
+	for _, m := range Modes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown content handling %q (expected %s)", mode, strings.Join(Modes, ", "))
+}
+
+// Content returns content as it is to be stored under mode. Content that cannot be masked,
+// binary files and malware samples, whose every fragment may match a signature, is stored
+// as its digest when masked.
+func Content(mode, category, content string) string {
+	if content == "" {
+		return ""
+	}
+	switch mode {
+	case Full:
+		return content
+	case Omit:
+		return ""
+	case Masked:
+		if category != CategoryMalware && utf8.ValidString(content) {
+			return mask(category, content)
+		}
+	}
+	return Digest(content)
+}
+
+// Digest returns the SHA-256 digest of content as sha256:<hex>
+func Digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// rule masks the matches of a pattern
+type rule struct {
+	pattern *regexp.Regexp
+	mask    func(match string) string
+}
+
+var (
+	// Card numbers keep the first and last four digits: 4532-****-****-9010
+	cardRule = rule{regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), func(m string) string {
+		return maskDigits(m, 4, 4)
+	}}
+	// US social security numbers keep the last four digits: ***-**-6789
+	ssnRule = rule{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), func(m string) string {
+		return maskDigits(m, 0, 4)
+	}}
+	// Passport numbers keep the letters and the last two digits: AB*****67
+	passportRule = rule{regexp.MustCompile(`\b[A-Z]{1,2}\d{6,9}\b`), func(m string) string {
+		letters := len(m) - len(strings.TrimLeft(m, "ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+		return m[:letters] + strings.Repeat("*", len(m)-letters-2) + m[len(m)-2:]
+	}}
+	// E-mail addresses keep the first character and the domain: j*******@example.com
+	emailRule = rule{regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`), func(m string) string {
+		at := strings.IndexByte(m, '@')
+		return m[:1] + strings.Repeat("*", at-1) + m[at:]
+	}}
+)
+
+// rules are the masking rules of each category, in the order they apply. Other categories,
+// such as CSV and spreadsheet uploads, can hold any of the data and get every rule.
+var rules = map[string][]rule{
+	CategoryCreditCard: {cardRule, ssnRule, emailRule},
+	CategoryPassport:   {passportRule},
+}
+
+var allRules = []rule{cardRule, ssnRule, passportRule, emailRule}
+
+func mask(category, content string) string {
+	categoryRules, ok := rules[category]
+	if !ok {
+		categoryRules = allRules
+	}
+	for _, r := range categoryRules {
+		content = r.pattern.ReplaceAllStringFunc(content, r.mask)
+	}
+	return content
+}
+
+// maskDigits replaces the digits of s with * except the first and last ones, keeping separators
+func maskDigits(s string, first, last int) string {
+	digits := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+
+	var b strings.Builder
+	i := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			if i >= first && i < digits-last {
+				c = '*'
+			}
+			i++
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	tests := []struct {
+		name     string
+		mode     string
+		category string
+		content  string
+		want     string
+	}{
+		{"card number", Masked, CategoryCreditCard, "Card: 4532-0151-1283-9010", "Card: 4532-****-****-9010"},
+		{"card number without separators", Masked, CategoryCreditCard, "4532015112839010", "4532********9010"},
+		{"social security number", Masked, CategoryCreditCard, "SSN: 123-45-6789", "SSN: ***-**-6789"},
+		{"e-mail address", Masked, CategoryCreditCard, "john.doe@example.com", "j*******@example.com"},
+		{"passport number", Masked, CategoryPassport, "Passport: AB1234567", "Passport: AB*****67"},
+		{"passport rules only", Masked, CategoryPassport, "4532015112839010", "4532015112839010"},
+		{"every rule for other categories", Masked, "csv", "AB1234567,123-45-6789", "AB*****67,***-**-6789"},
+		{"malware", Masked, CategoryMalware, eicar, Digest(eicar)},
+		{"binary", Masked, "xlsx", "PK\x03\x04\xff\xfe", Digest("PK\x03\x04\xff\xfe")},
+		{"full", Full, CategoryCreditCard, "4532015112839010", "4532015112839010"},
+		{"hash", Hash, CategoryCreditCard, "4532015112839010", Digest("4532015112839010")},
+		{"omit", Omit, CategoryCreditCard, "4532015112839010", ""},
+		{"empty", Hash, CategoryCreditCard, "", ""},
+	}
+	for _, tt := range tests {
+		if got := Content(tt.mode, tt.category, tt.content); got != tt.want {
+			t.Errorf("%s: Content(%s) = %q, want %q", tt.name, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	got := Digest("test")
+	if got != "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Errorf("Digest(test) = %s", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, mode := range Modes {
+		if err := Validate(mode); err != nil {
+			t.Errorf("Validate(%s) = %v", mode, err)
+		}
+	}
+	if err := Validate("plain"); err == nil || !strings.Contains(err.Error(), "masked") {
+		t.Errorf("Validate(plain) = %v, want an error listing the modes", err)
+	}
+}
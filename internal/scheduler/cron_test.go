@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"*/15 * * * *", true},
+		{"0 9-17 * * mon-fri", true},
+		{"30 2 1,15 * *", true},
+		{"0 0 * jan,jul sun", true},
+		{"0 0 * * 7", true},
+		{"@daily", true},
+		{"@HOURLY", true},
+		{"@every 90m", true},
+		{"* * * *", false},
+		{"60 * * * *", false},
+		{"0 24 * * *", false},
+		{"0 0 0 * *", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"0 0 * * funday", false},
+		{"0 0 31 feb *", false},
+		{"@every -1m", false},
+		{"@every soon", false},
+		{"@fortnightly", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseCron(%q) error = %v, want ok %v", tt.expr, err, tt.ok)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday 1 October 2025, 10:07
+	from := time.Date(2025, 10, 1, 10, 7, 30, 0, time.Local)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 10, 1, 10, 15, 0, 0, time.Local)},
+		{"7 10 * * *", time.Date(2025, 10, 2, 10, 7, 0, 0, time.Local)},
+		{"0 9 * * mon-fri", time.Date(2025, 10, 2, 9, 0, 0, 0, time.Local)},
+		{"0 0 * * sun", time.Date(2025, 10, 5, 0, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2025, 10, 5, 0, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2025, 11, 1, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+		// Either day field matches when both are restricted
+		{"0 12 15 * fri", time.Date(2025, 10, 3, 12, 0, 0, 0, time.Local)},
+		{"@every 90m", time.Date(2025, 10, 1, 11, 37, 30, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) = %v", tt.expr, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, from.Format(time.DateTime), got.Format(time.DateTime), tt.want.Format(time.DateTime))
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("", time.Hour)
+	if err != nil || s.String() != "@every 1h0m0s" {
+		t.Errorf("Parse(\"\", 1h) = %v, %v, want @every 1h0m0s", s, err)
+	}
+	if _, err := Parse("", 0); err == nil {
+		t.Error("Parse(\"\", 0) = nil error, want an invalid interval")
+	}
+}
@@ -0,0 +1,332 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// checkEvery bounds how long a job sleeps at once. Timers do not advance while the
+// machine is suspended, so jobs wake up regularly and compare against the wall clock.
+const checkEvery = time.Minute
+
+// missedAfter is how late a run may start before it counts as missed, e.g. after suspend
+const missedAfter = 2 * checkEvery
+
+// Options control how all jobs of a scheduler are run
+type Options struct {
+	// Jitter delays every run by a random duration up to Jitter, spreading a fleet of agents
+	Jitter time.Duration
+	// Blackout windows in which no job starts; runs due inside a window are deferred to its end
+	Blackout []Window
+	// CatchUp runs a job once when its run was missed while the machine was asleep or
+	// suspended. Without it the missed run is skipped and the next regular one is awaited.
+	CatchUp bool
+}
+
+// Status describes the schedule of a job
+type Status struct {
+	Name     string     `json:"check"`
+	Enabled  bool       `json:"enabled"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+}
+
+// Scheduler runs named jobs on their own schedule. Schedules and enabled flags
+// can be changed while the jobs are running.
+type Scheduler struct {
+	options Options
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []string
+	wg      sync.WaitGroup
+	started bool
+}
+
+type job struct {
+	name    string
+	run     func()
+	options Options
+	updates chan jobUpdate
+
+	mu       sync.Mutex
+	schedule Schedule
+	enabled  bool
+	nextRun  time.Time
+}
+
+type jobUpdate struct {
+	schedule Schedule
+	enabled  bool
+}
+
+func New(options Options) *Scheduler {
+	return &Scheduler{options: options, jobs: make(map[string]*job)}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(name string, schedule Schedule, enabled bool, run func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[name] = &job{
+		name:     name,
+		run:      run,
+		options:  s.options,
+		updates:  make(chan jobUpdate, 1),
+		schedule: schedule,
+		enabled:  enabled,
+	}
+	s.order = append(s.order, name)
+}
+
+// Start runs every job in its own goroutine until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+	for _, name := range s.order {
+		j := s.jobs[name]
+		j.start()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			j.loop(ctx)
+		}()
+	}
+}
+
+// Wait blocks until every job goroutine has stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Update changes the schedule and enabled flag of a job
+func (s *Scheduler) Update(name string, schedule Schedule, enabled bool) error {
+	if schedule == nil {
+		return fmt.Errorf("missing schedule for %s", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	if !s.started {
+		j.mu.Lock()
+		j.schedule, j.enabled = schedule, enabled
+		j.mu.Unlock()
+		return nil
+	}
+
+	// Replace a pending update that the job has not picked up yet
+	select {
+	case <-j.updates:
+	default:
+	}
+	j.updates <- jobUpdate{schedule: schedule, enabled: enabled}
+	return nil
+}
+
+// Statuses returns the schedule and next run time of every job, in the order they were added
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.order))
+	for _, name := range s.order {
+		statuses = append(statuses, s.jobs[name].status())
+	}
+	return statuses
+}
+
+// NextRun returns the next run time of a job, or false when it is disabled or unknown
+func (s *Scheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}, false
+	}
+
+	status := j.status()
+	if status.NextRun == nil {
+		return time.Time{}, false
+	}
+	return *status.NextRun, true
+}
+
+func (j *job) status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := Status{Name: j.name, Enabled: j.enabled, Schedule: j.schedule.String()}
+	if j.enabled && !j.nextRun.IsZero() {
+		next := j.nextRun
+		status.NextRun = &next
+	}
+	return status
+}
+
+// start plans the first run, so that it is known as soon as Start returns
+func (j *job) start() {
+	j.mu.Lock()
+	schedule, enabled := j.schedule, j.enabled
+	j.mu.Unlock()
+
+	var next time.Time
+	if enabled {
+		next = j.plan(firstRun(schedule, now()))
+	} else {
+		log.Printf("%s check disabled", j.name)
+	}
+	j.setNext(schedule, enabled, next)
+}
+
+func (j *job) loop(ctx context.Context) {
+	j.mu.Lock()
+	schedule, enabled, next := j.schedule, j.enabled, j.nextRun
+	j.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	stopTimer(timer)
+	defer timer.Stop()
+
+	for {
+		if enabled {
+			wait := next.Sub(now())
+			if wait > checkEvery {
+				wait = checkEvery
+			}
+			timer.Reset(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("%s check goroutine stopping...", j.name)
+			return
+
+		case update := <-j.updates:
+			stopTimer(timer)
+			if update.schedule.String() == schedule.String() && update.enabled == enabled {
+				continue
+			}
+			log.Printf("%s schedule changed: %s -> %s, enabled %v -> %v",
+				j.name, schedule, update.schedule, enabled, update.enabled)
+
+			wasEnabled := enabled
+			schedule, enabled = update.schedule, update.enabled
+			switch {
+			case !enabled:
+				next = time.Time{}
+			case !wasEnabled:
+				next = j.plan(firstRun(schedule, now()))
+			default:
+				next = j.plan(schedule.Next(now()))
+			}
+			j.setNext(schedule, enabled, next)
+
+		case <-timer.C:
+			current := now()
+			if current.Before(next) {
+				continue
+			}
+
+			// A run far past its time was missed while the machine slept
+			if late := current.Sub(next); late > missedAfter {
+				if !j.options.CatchUp {
+					log.Printf("%s check missed its run at %s, skipping", j.name, next.Format(time.RFC3339))
+					next = j.plan(schedule.Next(current))
+					j.setNext(schedule, enabled, next)
+					continue
+				}
+				log.Printf("%s check missed its run at %s, catching up", j.name, next.Format(time.RFC3339))
+			}
+
+			// A run that became due inside a blackout window, e.g. after suspend, waits for its end
+			if end, blocked := j.blackoutEnd(current); blocked {
+				log.Printf("%s check deferred to the end of a blackout window at %s", j.name, end.Format(time.RFC3339))
+				next = j.plan(end)
+				j.setNext(schedule, enabled, next)
+				continue
+			}
+
+			// Plan the following run before this one so it can be reported while running
+			next = j.plan(schedule.Next(current))
+			j.setNext(schedule, enabled, next)
+			j.run()
+		}
+	}
+}
+
+// plan adds jitter to a scheduled time and moves it out of blackout windows
+func (j *job) plan(t time.Time) time.Time {
+	t = t.Add(j.jitter())
+
+	// Adjacent or overlapping windows may need several moves
+	for i := 0; i <= len(j.options.Blackout); i++ {
+		end, blocked := j.blackoutEnd(t)
+		if !blocked {
+			break
+		}
+		t = end.Add(j.jitter())
+	}
+	return t
+}
+
+func (j *job) jitter() time.Duration {
+	if j.options.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(j.options.Jitter)))
+}
+
+// blackoutEnd returns the latest end of the blackout windows containing t
+func (j *job) blackoutEnd(t time.Time) (time.Time, bool) {
+	var latest time.Time
+	for _, w := range j.options.Blackout {
+		if end, ok := w.End(t); ok && end.After(latest) {
+			latest = end
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+func (j *job) setNext(schedule Schedule, enabled bool, next time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.schedule, j.enabled, j.nextRun = schedule, enabled, next
+}
+
+// firstRun returns when a job starts: interval jobs run immediately, cron jobs at their next time
+func firstRun(schedule Schedule, t time.Time) time.Time {
+	if _, ok := schedule.(every); ok {
+		return t
+	}
+	return schedule.Next(t)
+}
+
+// now returns the wall clock time without its monotonic reading, so that time spent
+// suspended is counted when comparing against planned run times
+func now() time.Time {
+	return time.Now().Round(0)
+}
+
+// stopTimer stops the timer and drains a pending tick
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring daily time range, e.g. "Mon-Fri 09:00-17:00" or "22:00-02:00",
+// in local time. Windows ending before they start run past midnight.
+type Window struct {
+	spec       string
+	days       uint8 // bit per weekday the window starts on
+	start, end time.Duration
+}
+
+// ParseWindow parses "[days ]HH:MM-HH:MM". Days are weekday names, ranges or lists
+// such as "Mon-Fri" or "Sat,Sun"; without them the window applies every day.
+func ParseWindow(spec string) (Window, error) {
+	spec = strings.TrimSpace(spec)
+	w := Window{spec: spec, days: 0x7f}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+	case 2:
+		bits, err := cronFields[4].parse(strings.ToLower(fields[0]))
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+		if bits&(1<<7) != 0 {
+			bits |= 1
+		}
+		w.days = uint8(bits & 0x7f)
+		fields = fields[1:]
+	default:
+		return Window{}, fmt.Errorf("invalid window %q: expected [days ]HH:MM-HH:MM", spec)
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.start == w.end {
+		return Window{}, fmt.Errorf("invalid window %q: empty time range", spec)
+	}
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// End returns the end of the window occurrence containing t, or false when t is outside the window
+func (w Window) End(t time.Time) (time.Time, bool) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	// An occurrence may have started today or, past midnight, yesterday
+	for _, day := range []time.Time{midnight, midnight.AddDate(0, 0, -1)} {
+		if w.days&(1<<uint(day.Weekday())) == 0 {
+			continue
+		}
+		start := day.Add(w.start)
+		end := day.Add(w.end)
+		if w.end < w.start {
+			end = day.AddDate(0, 0, 1).Add(w.end)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w Window) String() string {
+	return w.spec
+}
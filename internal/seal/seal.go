@@ -0,0 +1,176 @@
+// Package seal encrypts the results and settings the agent keeps on disk with AES-256-GCM.
+//
+// The key is derived from a secret kept in a key file or in the OS keyring (Secret Service on
+// Linux, Keychain on macOS, Credential Manager on Windows). A missing secret is generated on
+// first use. Sealed data starts with a header, so plain files written before encryption was
+// enabled can still be read, and are sealed when they are next written.
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Key sources. Keys derived from the enrollment credentials are made by the caller with NewKey.
+const (
+	SourceNone       = "none"
+	SourceFile       = "file"
+	SourceKeyring    = "keyring"
+	SourceEnrollment = "enrollment"
+)
+
+// Keyring entry holding the secret
+const (
+	keyringService = "dlpagent"
+	keyringUser    = "storage-key"
+)
+
+// header marks sealed data; the version selects the key derivation and cipher
+var header = []byte("DLPAGENT-SEALED-1\n")
+
+// ErrNoKey is returned when sealed data is read without a key
+var ErrNoKey = errors.New("data is encrypted but no encryption key is configured")
+
+// Key seals and opens data. A nil *Key leaves data in plain text.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// Load returns the key of source, creating its secret if needed. It returns nil for SourceNone.
+func Load(source, keyFile string) (*Key, error) {
+	var secret []byte
+	var err error
+	switch source {
+	case SourceNone, "":
+		return nil, nil
+	case SourceFile:
+		secret, err = fileSecret(keyFile)
+	case SourceKeyring:
+		secret, err = keyringSecret()
+	default:
+		return nil, fmt.Errorf("unknown key source %q (expected none, file or keyring)", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(secret)
+}
+
+// NewKey derives the key from a secret of at least 16 bytes
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("encryption secret is too short (%d bytes, need at least 16)", len(secret))
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, "dlpagent storage v1", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// Seal encrypts data, or returns it unchanged with a nil key
+func (k *Key) Seal(data []byte) []byte {
+	if k == nil {
+		return data
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	rand.Read(nonce)
+	out := append(append([]byte(nil), header...), nonce...)
+	return k.aead.Seal(out, nonce, data, header)
+}
+
+// Open decrypts sealed data. Data without the header is returned unchanged.
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	data = data[len(header):]
+	if len(data) < k.aead.NonceSize() {
+		return nil, errors.New("sealed data is truncated")
+	}
+	nonce, ciphertext := data[:k.aead.NonceSize()], data[k.aead.NonceSize():]
+	plain, err := k.aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, errors.New("failed to decrypt: wrong key or damaged data")
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data was sealed
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, header)
+}
+
+// fileSecret reads the secret from path, creating the file with a random secret if it does not exist
+func fileSecret(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("no key file configured")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		secret := newSecret()
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key file: %w", err)
+		}
+		_, err = file.WriteString(secret + "\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write key file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Created encryption key file %s; keep a copy to read the results elsewhere\n", path)
+		return []byte(secret), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// keyringSecret reads the secret from the OS keyring, storing a random one if there is none
+func keyringSecret() ([]byte, error) {
+	secret, err := keyring.Get(keyringService, keyringUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		secret = newSecret()
+		if err := keyring.Set(keyringService, keyringUser, secret); err != nil {
+			return nil, fmt.Errorf("failed to store encryption key in the OS keyring: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Stored a new encryption key in the OS keyring (%s/%s)\n", keyringService, keyringUser)
+		return []byte(secret), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key from the OS keyring: %w", err)
+	}
+	return []byte(strings.TrimSpace(secret)), nil
+}
+
+// newSecret returns 32 random bytes, hex-encoded
+func newSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
@@ -0,0 +1,106 @@
+package seal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := NewKey([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewKey([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte(`{"results": []}`)
+	sealed := key.Seal(plain)
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		key     *Key
+		data    []byte
+		want    []byte
+		wantErr string
+	}{
+		{name: "sealed", key: key, data: sealed, want: plain},
+		{name: "plain data written before encryption", key: key, data: plain, want: plain},
+		{name: "plain data without key", key: nil, data: plain, want: plain},
+		{name: "wrong key", key: other, data: sealed, wantErr: "failed to decrypt"},
+		{name: "tampered", key: key, data: tampered, wantErr: "failed to decrypt"},
+		{name: "truncated", key: key, data: sealed[:len(header)+4], wantErr: "truncated"},
+		{name: "sealed without key", key: nil, data: sealed, wantErr: ErrNoKey.Error()},
+	}
+	for _, tt := range tests {
+		got, err := tt.key.Open(tt.data)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: Open() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Open() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSeal(t *testing.T) {
+	key, err := NewKey([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("secret")
+	a, b := key.Seal(plain), key.Seal(plain)
+	if !IsSealed(a) || bytes.Contains(a, plain) {
+		t.Errorf("Seal() = %q, want sealed data without the plain text", a)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("Seal() returned the same data twice, want a new nonce each time")
+	}
+	var none *Key
+	if got := none.Seal(plain); !bytes.Equal(got, plain) {
+		t.Errorf("nil Key Seal() = %q, want plain text", got)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	if _, err := NewKey([]byte("short")); err == nil {
+		t.Error("NewKey() with a 5-byte secret = nil error")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.key")
+	first, err := Load(SourceFile, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("key file mode = %v, want no access for others", info.Mode().Perm())
+	}
+
+	// The key is read back from the file
+	second, err := Load(SourceFile, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Open(first.Seal([]byte("secret"))); err != nil {
+		t.Errorf("key loaded again cannot open data: %v", err)
+	}
+
+	if key, err := Load(SourceNone, ""); key != nil || err != nil {
+		t.Errorf("Load(none) = %v, %v, want nil, nil", key, err)
+	}
+}
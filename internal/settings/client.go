@@ -0,0 +1,240 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dlpagent/internal/jsonfile"
+	"dlpagent/internal/seal"
+	"dlpagent/internal/signing"
+)
+
+// SettingsPath is the settings endpoint relative to the server URL
+const SettingsPath = "/api/settings-agent"
+
+// DefaultMaxAge is how long fetched settings are used before they are revalidated
+const DefaultMaxAge = 5 * time.Minute
+
+// Client fetches agent settings from the server. Settings are cached in memory for
+// MaxAge, revalidated with If-None-Match, and the last known good settings are kept
+// on disk so a failing server does not stop the agent.
+type Client struct {
+	serverURL string
+	cachePath string
+	MaxAge    time.Duration
+	CacheKey  *seal.Key         // seals the disk cache; nil keeps it in plain text
+	Verifier  *signing.Verifier // checks the signature of fetched settings; nil accepts them unchecked
+
+	client   *http.Client
+	reload   chan struct{}
+	fetching chan struct{} // held while a request is in flight
+
+	mu        sync.Mutex
+	current   *Settings
+	etag      string
+	fetchedAt time.Time
+}
+
+// NewClient creates a settings client for the server at serverURL (e.g. http://127.0.0.1:8000).
+// cachePath is the file the last known good settings are stored in; empty disables the disk cache.
+// A nil httpClient uses a default client.
+func NewClient(serverURL, cachePath string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		cachePath: cachePath,
+		MaxAge:    DefaultMaxAge,
+		client:    httpClient,
+		reload:    make(chan struct{}, 1),
+		fetching:  make(chan struct{}, 1),
+	}
+}
+
+// ServerURL returns the base URL of the server
+func (c *Client) ServerURL() string {
+	return c.serverURL
+}
+
+// Get returns the current settings. When the server cannot be reached or returns an
+// invalid document, the last known good settings are returned together with the error
+// wrapped in ErrStale. An error without settings is returned only if none are known.
+func (c *Client) Get(ctx context.Context) (*Settings, error) {
+	return c.get(ctx, false)
+}
+
+// ErrStale is returned with the last known good settings when fresh settings could not be fetched
+var ErrStale = errors.New("using last known good settings")
+
+func (c *Client) copyCurrent() *Settings {
+	return c.current.clone()
+}
+
+// Current returns the cached settings without contacting the server, or nil if none are known
+func (c *Client) Current() *Settings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		c.loadCache()
+	}
+	if c.current == nil {
+		return nil
+	}
+	return c.copyCurrent()
+}
+
+// Refresh revalidates the settings with the server regardless of their age
+func (c *Client) Refresh(ctx context.Context) (*Settings, error) {
+	return c.get(ctx, true)
+}
+
+// get returns the current settings, fetching them when they are older than MaxAge or force is
+// set. One request is made at a time; the mutex is not held during it, so Current does not wait.
+func (c *Client) get(ctx context.Context, force bool) (*Settings, error) {
+	select {
+	case c.fetching <- struct{}{}:
+		defer func() { <-c.fetching }()
+	case <-ctx.Done():
+		return c.Current(), ctx.Err()
+	}
+
+	c.mu.Lock()
+	if c.current == nil {
+		c.loadCache()
+	}
+	if c.current != nil && !force && time.Since(c.fetchedAt) < c.MaxAge {
+		defer c.mu.Unlock()
+		return c.copyCurrent(), nil
+	}
+	etag := ""
+	if c.current != nil {
+		etag = c.etag
+	}
+	c.mu.Unlock()
+
+	fetched, etag, err := c.fetch(ctx, etag)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if c.current != nil {
+			return c.copyCurrent(), fmt.Errorf("%w: %v", ErrStale, err)
+		}
+		return nil, err
+	}
+	// A nil document means the cached one is still current
+	if fetched != nil {
+		c.current = fetched
+		c.etag = etag
+	}
+	c.fetchedAt = time.Now()
+	c.saveCache()
+	return c.copyCurrent(), nil
+}
+
+// fetch requests the settings, revalidating the cached copy with its ETag. It returns the new
+// settings and their ETag, or nil settings when the cached copy has not been modified.
+// Documents that are not a successful response with usable test URLs are rejected, so they
+// never replace the last known good settings.
+func (c *Client) fetch(ctx context.Context, etag string) (*Settings, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.serverURL+SettingsPath, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create settings request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, etag, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read settings response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("settings request failed with status %d: %s", resp.StatusCode, preview(body))
+	}
+
+	// Check if response is actually JSON
+	if len(body) > 0 && body[0] != '{' && body[0] != '[' {
+		return nil, "", fmt.Errorf("server returned non-JSON settings response: %s", preview(body))
+	}
+	body, err = c.Verifier.Open(signing.KindSettings, body)
+	if err != nil {
+		return nil, "", fmt.Errorf("settings rejected: %w", err)
+	}
+
+	var settingsResp SettingsResponse
+	if err := json.Unmarshal(body, &settingsResp); err != nil {
+		return nil, "", fmt.Errorf("failed to parse settings response: %w", err)
+	}
+	if !settingsResp.Success {
+		return nil, "", fmt.Errorf("server reported failure in settings response: %s", preview(body))
+	}
+	if err := settingsResp.Data.validate(); err != nil {
+		return nil, "", fmt.Errorf("settings rejected: %w", err)
+	}
+
+	return &settingsResp.Data, resp.Header.Get("ETag"), nil
+}
+
+// loadCache restores the last known good settings from disk
+func (c *Client) loadCache() {
+	if c.cachePath == "" {
+		return
+	}
+
+	var entry cacheEntry
+	if err := jsonfile.Read(c.cachePath, c.CacheKey, &entry); err != nil || entry.FetchedAt.IsZero() {
+		return
+	}
+
+	c.current = &entry.Settings
+	c.etag = entry.ETag
+	c.fetchedAt = entry.FetchedAt
+}
+
+// saveCache stores the current settings on disk as the last known good copy. A failed write
+// only costs the copy used when the server is unreachable after a restart, so it is logged and
+// the settings are still used.
+func (c *Client) saveCache() {
+	if c.cachePath == "" || c.current == nil {
+		return
+	}
+
+	err := jsonfile.Write(c.cachePath, c.CacheKey, cacheEntry{
+		ETag:      c.etag,
+		FetchedAt: c.fetchedAt,
+		Settings:  *c.current,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to save settings cache %s: %v", c.cachePath, err)
+	}
+}
+
+func preview(body []byte) string {
+	if len(body) > 200 {
+		return string(body[:200])
+	}
+	return string(body)
+}
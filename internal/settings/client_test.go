@@ -0,0 +1,96 @@
+package settings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchKeepsLastKnownGood(t *testing.T) {
+	const good = `{"success": true, "data": {"url_dlp": "http://dlp.example/upload", "url_antivirus": "http://av.example/eicar"}}`
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"failure", `{"success": false, "data": {"url_dlp": "http://other.example/"}}`},
+		{"error envelope", `{"success": false, "error": "internal error"}`},
+		{"empty object", `{}`},
+		{"no test URLs", `{"success": true, "data": {}}`},
+		{"invalid URL", `{"success": true, "data": {"url_dlp": "file:///etc/passwd"}}`},
+		{"not JSON", `<html>maintenance</html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := good
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			cachePath := filepath.Join(t.TempDir(), "settings_cache.json")
+			client := NewClient(server.URL, cachePath, server.Client())
+			if _, err := client.Refresh(context.Background()); err != nil {
+				t.Fatalf("Refresh() with valid settings = %v", err)
+			}
+
+			body = tt.body
+			s, err := client.Refresh(context.Background())
+			if !errors.Is(err, ErrStale) {
+				t.Fatalf("Refresh() error = %v, want ErrStale", err)
+			}
+			if s == nil || s.URLDLP != "http://dlp.example/upload" {
+				t.Fatalf("Refresh() settings = %+v, want the last known good ones", s)
+			}
+
+			// The disk cache still holds the good settings
+			cached := NewClient(server.URL, cachePath, server.Client()).Current()
+			if cached == nil || cached.URLDLP != "http://dlp.example/upload" {
+				t.Errorf("cached settings = %+v, want the last known good ones", cached)
+			}
+		})
+	}
+}
+
+func TestGetCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(server.URL, "", server.Client())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Get(ctx); err == nil {
+		t.Fatal("Get() with a cancelled context = nil error")
+	}
+}
+
+func TestRefreshCacheWriteFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success": true, "data": {"url_dlp": "http://dlp.example/upload"}}`))
+	}))
+	defer server.Close()
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	cachePath := filepath.Join(t.TempDir(), "missing", "settings_cache.json")
+	s, err := NewClient(server.URL, cachePath, server.Client()).Refresh(context.Background())
+	if err != nil || s == nil || s.URLDLP != "http://dlp.example/upload" {
+		t.Fatalf("Refresh() = %+v, %v, want the fetched settings", s, err)
+	}
+	if !strings.Contains(logged.String(), "failed to save settings cache") {
+		t.Errorf("log = %q, want a warning about the cache", logged.String())
+	}
+}
@@ -0,0 +1,136 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"dlpagent/internal/scheduler"
+)
+
+// Settings is the agent configuration served by /api/settings-agent
+type Settings struct {
+	URLDLP       string                   `json:"url_dlp"`
+	URLAntivirus string                   `json:"url_antivirus"`
+	DLPFiles     []string                 `json:"dlp_files,omitempty"` // test files on the endpoint for the DLP check
+	Checks       map[string]CheckSettings `json:"checks,omitempty"`    // keyed by check: antivirus, dlp, ransomware, edr
+}
+
+// CheckSettings is the server-driven schedule of a single check. Unset fields keep the local configuration.
+type CheckSettings struct {
+	Enabled         *bool  `json:"enabled,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+	Cron            string `json:"cron,omitempty"` // cron expression, takes precedence over the interval
+}
+
+// MinInterval is the shortest check interval accepted from the server
+const MinInterval = time.Minute
+
+// Schedule returns the interval and enabled flag of a check, with the values pushed
+// by the server overriding the local ones
+func (s *Settings) Schedule(check string, interval time.Duration, enabled bool) (time.Duration, bool) {
+	if s == nil {
+		return interval, enabled
+	}
+
+	cs, ok := s.Checks[check]
+	if !ok {
+		return interval, enabled
+	}
+	if cs.IntervalSeconds > 0 {
+		interval = time.Duration(cs.IntervalSeconds) * time.Second
+		if interval < MinInterval {
+			interval = MinInterval
+		}
+	}
+	if cs.Enabled != nil {
+		enabled = *cs.Enabled
+	}
+	return interval, enabled
+}
+
+// Cron returns the cron expression of a check. A cron expression or interval pushed by the
+// server replaces the local expression; otherwise the local one is kept.
+func (s *Settings) Cron(check, expr string) string {
+	if s == nil {
+		return expr
+	}
+
+	cs, ok := s.Checks[check]
+	switch {
+	case !ok:
+		return expr
+	case cs.Cron != "":
+		return clampCron(cs.Cron)
+	case cs.IntervalSeconds > 0:
+		return ""
+	}
+	return expr
+}
+
+// clampCron returns expr, or an interval of MinInterval if expr runs more often than that.
+// Invalid expressions are returned as they are, for the caller to report.
+func clampCron(expr string) string {
+	schedule, err := scheduler.ParseCron(expr)
+	if err != nil {
+		return expr
+	}
+	// Compare the gaps between the next few runs
+	t := schedule.Next(time.Now())
+	for i := 0; i < 10; i++ {
+		next := schedule.Next(t)
+		if next.Sub(t) < MinInterval {
+			return scheduler.Every(MinInterval).String()
+		}
+		t = next
+	}
+	return expr
+}
+
+// validate rejects settings the checks cannot run with: no test URL at all, or one that is not
+// an absolute http(s) URL
+func (s *Settings) validate() error {
+	if s.URLDLP == "" && s.URLAntivirus == "" {
+		return errors.New("no test URLs (url_dlp, url_antivirus)")
+	}
+	for key, value := range map[string]string{"url_dlp": s.URLDLP, "url_antivirus": s.URLAntivirus} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL, got %q", key, value)
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy so callers cannot modify the cached settings
+func (s *Settings) clone() *Settings {
+	c := *s
+	c.DLPFiles = append([]string(nil), s.DLPFiles...)
+	if s.Checks != nil {
+		c.Checks = make(map[string]CheckSettings, len(s.Checks))
+		for name, cs := range s.Checks {
+			if cs.Enabled != nil {
+				enabled := *cs.Enabled
+				cs.Enabled = &enabled
+			}
+			c.Checks[name] = cs
+		}
+	}
+	return &c
+}
+
+// SettingsResponse represents the response from /api/settings-agent endpoint
+type SettingsResponse struct {
+	Success bool     `json:"success"`
+	Data    Settings `json:"data"`
+}
+
+// cacheEntry is the last known good settings document stored on disk
+type cacheEntry struct {
+	ETag      string    `json:"etag"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Settings  Settings  `json:"settings"`
+}
@@ -0,0 +1,25 @@
+package settings
+
+import "testing"
+
+func TestCron(t *testing.T) {
+	tests := []struct {
+		name  string
+		check CheckSettings
+		want  string
+	}{
+		{"every minute", CheckSettings{Cron: "* * * * *"}, "* * * * *"},
+		{"hourly", CheckSettings{Cron: "@hourly"}, "@hourly"},
+		{"every second", CheckSettings{Cron: "@every 1s"}, "@every 1m0s"},
+		{"every 30 seconds", CheckSettings{Cron: "@every 30s"}, "@every 1m0s"},
+		{"invalid", CheckSettings{Cron: "often"}, "often"},
+		{"interval replaces the local expression", CheckSettings{IntervalSeconds: 600}, ""},
+		{"nothing pushed", CheckSettings{}, "0 * * * *"},
+	}
+	for _, tt := range tests {
+		s := &Settings{Checks: map[string]CheckSettings{"dlp": tt.check}}
+		if got := s.Cron("dlp", "0 * * * *"); got != tt.want {
+			t.Errorf("%s: Cron() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
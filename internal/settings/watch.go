@@ -0,0 +1,88 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// Watch polls the server every interval and calls onChange whenever the settings differ
+// from the ones seen before. A reload requested with RequestReload or a push polls at once.
+// Watch returns when ctx is cancelled.
+func (c *Client) Watch(ctx context.Context, interval time.Duration, onChange func(*Settings)) {
+	last := c.Current()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.reload:
+			log.Println("Settings reload requested")
+		}
+
+		s, err := c.Refresh(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, ErrStale) {
+				log.Printf("Warning: failed to refresh settings: %v", err)
+				continue
+			}
+			log.Printf("Warning: %v", err)
+		}
+
+		if reflect.DeepEqual(s, last) {
+			continue
+		}
+		log.Println("Settings changed, applying new configuration")
+		last = s
+		onChange(s)
+	}
+}
+
+// RequestReload makes Watch fetch the settings without waiting for the next poll
+func (c *Client) RequestReload() {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+}
+
+// PushHandler accepts POST notifications from the server that the settings changed.
+// The notification carries no settings; they are always fetched from the server.
+func (c *Client) PushHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		c.RequestReload()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// ListenForPush serves PushHandler on addr at /reload until ctx is cancelled
+func (c *Client) ListenForPush(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/reload", c.PushHandler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
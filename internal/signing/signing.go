@@ -0,0 +1,158 @@
+// Package signing verifies the settings and test catalogs the server sends, so a compromised or
+// spoofed server cannot point the agent at other URLs or files.
+//
+// A signed document is an envelope around the original response:
+//
+//	{"signed": {"type": "settings", "version": 42, "expires": "2026-11-01T00:00:00Z", "document": {...}},
+//	 "signature": "<Base64 Ed25519 signature of the bytes of signed>"}
+//
+// The version of every document type may only grow: the highest accepted version is kept in a
+// state file and older documents are rejected as replays.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"dlpagent/internal/jsonfile"
+)
+
+// Document types
+const (
+	KindSettings         = "settings"
+	KindAntivirusCatalog = "antivirus_catalog"
+)
+
+// ErrUnsigned is returned for unsigned documents in strict mode
+var ErrUnsigned = errors.New("document is not signed")
+
+// envelope is a signed document as sent by the server
+type envelope struct {
+	Signed    json.RawMessage `json:"signed"`
+	Signature string          `json:"signature"`
+}
+
+// signed is the signed part of an envelope
+type signed struct {
+	Type     string          `json:"type"`
+	Version  uint64          `json:"version"`
+	Expires  time.Time       `json:"expires"`
+	Document json.RawMessage `json:"document"`
+}
+
+// state holds the highest accepted version of every document type
+type state struct {
+	Versions map[string]uint64 `json:"versions"`
+}
+
+// Verifier checks signed documents against pinned public keys. A nil *Verifier accepts every
+// document unchecked.
+type Verifier struct {
+	keys      []ed25519.PublicKey
+	strict    bool
+	statePath string
+	now       func() time.Time
+}
+
+// NewVerifier creates a verifier for the Base64 Ed25519 public keys. In strict mode unsigned
+// documents are refused. The accepted versions are kept in statePath.
+func NewVerifier(keys []string, strict bool, statePath string) (*Verifier, error) {
+	v := &Verifier{strict: strict, statePath: statePath, now: time.Now}
+	for _, key := range keys {
+		pub, err := ParseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, pub)
+	}
+	if strict && len(v.keys) == 0 {
+		return nil, errors.New("strict mode requires a public key")
+	}
+	return v, nil
+}
+
+// ParseKey decodes a Base64 Ed25519 public key
+func ParseKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q: expected %d Base64-encoded bytes", key, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Open verifies a document of the given type and returns the original document. Unsigned
+// documents are returned as they are unless the verifier is strict.
+func (v *Verifier) Open(kind string, body []byte) ([]byte, error) {
+	if v == nil {
+		return body, nil
+	}
+
+	var env envelope
+	if json.Unmarshal(body, &env) != nil || len(env.Signed) == 0 {
+		if v.strict {
+			return nil, fmt.Errorf("%w and signing.strict is enabled", ErrUnsigned)
+		}
+		return body, nil
+	}
+
+	if len(v.keys) > 0 {
+		signature, err := base64.StdEncoding.DecodeString(env.Signature)
+		if err != nil {
+			return nil, errors.New("invalid signature encoding")
+		}
+		if !v.verify(env.Signed, signature) {
+			return nil, errors.New("signature does not match any pinned public key")
+		}
+	}
+
+	var doc signed
+	if err := json.Unmarshal(env.Signed, &doc); err != nil {
+		return nil, fmt.Errorf("invalid signed document: %w", err)
+	}
+	if doc.Type != kind {
+		return nil, fmt.Errorf("signed document is of type %q, expected %q", doc.Type, kind)
+	}
+	if !doc.Expires.IsZero() && v.now().After(doc.Expires) {
+		return nil, fmt.Errorf("signed document version %d expired at %s", doc.Version, doc.Expires.Format(time.RFC3339))
+	}
+	if len(bytes.TrimSpace(doc.Document)) == 0 {
+		return nil, errors.New("signed document is empty")
+	}
+	if err := v.accept(kind, doc.Version); err != nil {
+		return nil, err
+	}
+	return doc.Document, nil
+}
+
+func (v *Verifier) verify(message, signature []byte) bool {
+	for _, key := range v.keys {
+		if ed25519.Verify(key, message, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// accept records version as the highest of kind, or rejects it if a newer one was accepted before.
+// The same version may be fetched again.
+func (v *Verifier) accept(kind string, version uint64) error {
+	if v.statePath == "" {
+		return nil
+	}
+	var s state
+	return jsonfile.Update(v.statePath, nil, &s, func() error {
+		if s.Versions == nil {
+			s.Versions = map[string]uint64{}
+		}
+		if last := s.Versions[kind]; version < last {
+			return fmt.Errorf("signed document version %d is older than the accepted version %d (replayed?)", version, last)
+		}
+		s.Versions[kind] = version
+		return nil
+	})
+}
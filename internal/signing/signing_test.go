@@ -0,0 +1,125 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// sign returns an envelope around document, signed with key
+func sign(t *testing.T, key ed25519.PrivateKey, kind string, version uint64, expires time.Time, document string) []byte {
+	t.Helper()
+	inner, err := json.Marshal(signed{Type: kind, Version: version, Expires: expires, Document: json.RawMessage(document)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(envelope{Signed: inner, Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, inner))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func newKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pub), priv
+}
+
+func TestOpen(t *testing.T) {
+	pub, priv := newKey(t)
+	_, other := newKey(t)
+	const document = `{"url_dlp":"https://dlp.example/upload"}`
+	later := now.Add(time.Hour)
+
+	tampered := sign(t, priv, KindSettings, 2, later, document)
+	tampered = []byte(strings.Replace(string(tampered), "dlp.example", "evil.example", 1))
+
+	tests := []struct {
+		name    string
+		strict  bool
+		body    []byte
+		wantErr string
+	}{
+		{name: "valid", body: sign(t, priv, KindSettings, 2, later, document)},
+		{name: "same version again", body: sign(t, priv, KindSettings, 1, later, document)},
+		{name: "without expiry", body: sign(t, priv, KindSettings, 1, time.Time{}, document)},
+		{name: "tampered", body: tampered, wantErr: "does not match"},
+		{name: "unknown key", body: sign(t, other, KindSettings, 2, later, document), wantErr: "does not match"},
+		{name: "expired", body: sign(t, priv, KindSettings, 2, now.Add(-time.Minute), document), wantErr: "expired"},
+		{name: "replayed", body: sign(t, priv, KindSettings, 0, later, document), wantErr: "replayed"},
+		{name: "other type", body: sign(t, priv, KindAntivirusCatalog, 2, later, document), wantErr: "type"},
+		{name: "unsigned", body: []byte(document)},
+		{name: "unsigned in strict mode", strict: true, body: []byte(document), wantErr: ErrUnsigned.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier([]string{pub}, tt.strict, filepath.Join(t.TempDir(), "signing_state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.now = func() time.Time { return now }
+			// Version 1 was accepted before
+			if _, err := v.Open(KindSettings, sign(t, priv, KindSettings, 1, later, document)); err != nil {
+				t.Fatalf("Open() of version 1 = %v", err)
+			}
+
+			got, err := v.Open(KindSettings, tt.body)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Open() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open() = %v", err)
+			}
+			if string(got) != document {
+				t.Errorf("Open() = %s, want %s", got, document)
+			}
+		})
+	}
+}
+
+func TestStrictUnsignedIsErrUnsigned(t *testing.T) {
+	pub, _ := newKey(t)
+	v, err := NewVerifier([]string{pub}, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Open(KindAntivirusCatalog, []byte(`{"success": true, "data": []}`)); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Open() error = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	pub, _ := newKey(t)
+	tests := []struct {
+		name   string
+		keys   []string
+		strict bool
+		ok     bool
+	}{
+		{"key", []string{pub}, false, true},
+		{"strict with key", []string{pub}, true, true},
+		{"strict without key", nil, true, false},
+		{"invalid key", []string{"AAAA"}, false, false},
+	}
+	for _, tt := range tests {
+		_, err := NewVerifier(tt.keys, tt.strict, "")
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: NewVerifier() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
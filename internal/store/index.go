@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"dlpagent/internal/history"
+)
+
+// Buckets. Records are stored in results by ID. The index buckets map a key ending in the
+// timestamp and ID of a record to nothing, so a cursor finds the records of a time range.
+var (
+	bucketMeta     = []byte("meta")
+	bucketResults  = []byte("results")
+	bucketTime     = []byte("by_time")     // timestamp, ID
+	bucketCheck    = []byte("by_check")    // check, 0, timestamp, ID
+	bucketCategory = []byte("by_category") // category, 0, timestamp, ID
+	bucketStatus   = []byte("by_status")   // status, 0, timestamp, ID
+	bucketHosts    = []byte("hosts")       // host descriptions by ID, see Record.HostID
+)
+
+// ref is the timestamp and ID of a record found in an index
+type ref struct {
+	timestamp []byte
+	id        []byte
+}
+
+// after orders refs newest first
+func (r ref) after(other ref) bool {
+	if c := bytes.Compare(r.timestamp, other.timestamp); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(r.id, other.id) > 0
+}
+
+func encodeUint(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// encodeTime encodes t so that keys sort in time order; times before 1970 sort first
+func encodeTime(t time.Time) []byte {
+	if t.Before(time.Unix(0, 0)) {
+		return encodeUint(0)
+	}
+	return encodeUint(uint64(t.UnixNano()))
+}
+
+// indexKeys returns the key of the record in every index bucket
+func indexKeys(r Record) map[string][]byte {
+	suffix := append(encodeTime(r.Timestamp), encodeUint(r.ID)...)
+	prefixed := func(value string) []byte {
+		return append(append([]byte(value), 0), suffix...)
+	}
+	return map[string][]byte{
+		string(bucketTime):     suffix,
+		string(bucketCheck):    prefixed(r.Check),
+		string(bucketCategory): prefixed(string(r.Category)),
+		string(bucketStatus):   prefixed(string(r.Status)),
+	}
+}
+
+// put writes a record with its ID set and its index keys. The record is sealed, the index keys
+// with its time, check, category and status are not.
+func (s *Store) put(tx *bolt.Tx, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketResults).Put(encodeUint(r.ID), s.options.Key.Seal(data)); err != nil {
+		return err
+	}
+	for bucket, key := range indexKeys(r) {
+		if err := tx.Bucket([]byte(bucket)).Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get reads the record with the given ID, or nil if there is none
+func (s *Store) get(tx *bolt.Tx, id []byte) (*Record, error) {
+	data := tx.Bucket(bucketResults).Get(id)
+	if data == nil {
+		return nil, nil
+	}
+	plain, err := s.options.Key.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", binary.BigEndian.Uint64(id), err)
+	}
+	var r Record
+	if err := json.Unmarshal(plain, &r); err != nil {
+		return nil, fmt.Errorf("record %d: %w", binary.BigEndian.Uint64(id), err)
+	}
+	return &r, nil
+}
+
+// remove deletes the record with the given ID and its index keys and returns it, or nil if
+// there is none
+func (s *Store) remove(tx *bolt.Tx, id []byte) (*Record, error) {
+	r, err := s.get(tx, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	results := tx.Bucket(bucketResults)
+	for bucket, key := range indexKeys(*r) {
+		if err := tx.Bucket([]byte(bucket)).Delete(key); err != nil {
+			return nil, err
+		}
+	}
+	return r, results.Delete(id)
+}
+
+// scan finds the records in the time range of q with the most selective index for its filters.
+// The other filters of q still have to be applied to the records.
+func scan(tx *bolt.Tx, q history.Query) []ref {
+	bucket, values := bucketTime, []string{""}
+	switch {
+	case len(q.Checks) > 0:
+		bucket, values = bucketCheck, q.Checks
+	case len(q.Statuses) > 0:
+		bucket, values = bucketStatus, toStrings(q.Statuses)
+	case len(q.Categories) > 0:
+		bucket, values = bucketCategory, toStrings(q.Categories)
+	}
+
+	var refs []ref
+	for _, value := range values {
+		var prefix []byte
+		if !bytes.Equal(bucket, bucketTime) {
+			prefix = append([]byte(value), 0)
+		}
+		start := append(append([]byte(nil), prefix...), encodeTime(q.Since)...)
+		var end []byte
+		if !q.Until.IsZero() {
+			end = append(append([]byte(nil), prefix...), encodeTime(q.Until)...)
+		}
+
+		c := tx.Bucket(bucket).Cursor()
+		for k, _ := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+			rest := append([]byte(nil), k[len(prefix):]...)
+			refs = append(refs, ref{timestamp: rest[:8], id: rest[8:16]})
+		}
+	}
+	return refs
+}
+
+func toStrings[T ~string](values []T) []string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = string(v)
+	}
+	return strs
+}
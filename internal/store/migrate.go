@@ -0,0 +1,67 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// versionKey in the meta bucket holds the schema version, the number of migrations applied
+var versionKey = []byte("schema_version")
+
+// migrations upgrade the schema one version at a time; migrations[i] upgrades version i to i+1.
+// Append new migrations, never change released ones.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: results with indexes by time, check, category and status
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketResults, bucketTime, bucketCheck, bucketCategory, bucketStatus} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 2: host descriptions referenced by results
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketHosts)
+		return err
+	},
+}
+
+// migrate applies the migrations the database has not had yet, in a single transaction
+func migrate(db *bolt.DB) error {
+	var current uint64
+	err := db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(bucketMeta); meta != nil {
+			if v := meta.Get(versionKey); len(v) == 8 {
+				current = binary.BigEndian.Uint64(v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	latest := uint64(len(migrations))
+	if current > latest {
+		return fmt.Errorf("schema version %d is newer than this agent supports (%d)", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		for v := current; v < latest; v++ {
+			if err := migrations[v](tx); err != nil {
+				return fmt.Errorf("migration to version %d: %w", v+1, err)
+			}
+		}
+		return meta.Put(versionKey, encodeUint(latest))
+	})
+}
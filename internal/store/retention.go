@@ -0,0 +1,71 @@
+package store
+
+import (
+	"bytes"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// prune removes the records older than MaxAge, then the oldest records beyond MaxCount, and the
+// host descriptions no remaining record refers to. It returns the number of records removed.
+func (s *Store) prune(tx *bolt.Tx, now time.Time) (int, error) {
+	var expired [][]byte
+	total := 0
+	c := tx.Bucket(bucketTime).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		total++
+	}
+	excess := 0
+	if s.options.MaxCount > 0 && total > s.options.MaxCount {
+		excess = total - s.options.MaxCount
+	}
+	var cutoff []byte
+	if s.options.MaxAge > 0 {
+		cutoff = encodeTime(now.Add(-s.options.MaxAge))
+	}
+
+	// The time index is in ascending order, so the records to remove come first
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		old := cutoff != nil && bytes.Compare(k[:8], cutoff) < 0
+		if !old && len(expired) >= excess {
+			break
+		}
+		expired = append(expired, append([]byte(nil), k[8:16]...))
+	}
+
+	hosts := map[string]bool{}
+	for _, id := range expired {
+		r, err := s.remove(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if r != nil && r.HostID != "" {
+			hosts[r.HostID] = true
+		}
+	}
+	if err := s.pruneHosts(tx, hosts); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// pruneHosts deletes the given host descriptions unless a record still refers to them. Newer
+// records are the likeliest to share a host, so they are read first.
+func (s *Store) pruneHosts(tx *bolt.Tx, hosts map[string]bool) error {
+	c := tx.Bucket(bucketResults).Cursor()
+	for k, _ := c.Last(); k != nil && len(hosts) > 0; k, _ = c.Prev() {
+		r, err := s.get(tx, k)
+		if err != nil {
+			// A record that cannot be read may refer to any of them
+			return nil
+		}
+		delete(hosts, r.HostID)
+	}
+	for id := range hosts {
+		if err := tx.Bucket(bucketHosts).Delete([]byte(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dlpagent/internal/history"
+)
+
+func TestPruneHosts(t *testing.T) {
+	st := New(filepath.Join(t.TempDir(), "results.db"), Options{MaxCount: 2})
+	add := func(host string, age time.Duration) string {
+		t.Helper()
+		id, err := st.AddHost(json.RawMessage(`{"hostname": "` + host + `"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := Record{Entry: history.Entry{Timestamp: time.Now().Add(-age), Check: "dlp"}, HostID: id}
+		if err := st.Add(record); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	old := add("old", 3*time.Hour)
+	shared := add("shared", 2*time.Hour)
+	add("shared", time.Hour)
+	add("new", 0)
+
+	tests := []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{"host of pruned records only", old, false},
+		{"host of a pruned and a kept record", shared, true},
+	}
+	for _, tt := range tests {
+		data, err := st.Host(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := data != nil; kept != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.name, kept, tt.kept)
+		}
+	}
+}
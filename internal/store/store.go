@@ -0,0 +1,303 @@
+// Package store keeps the results of every check run in an embedded bbolt database,
+// indexed by time, check, category and status, with retention by age and count.
+//
+// The database is opened for each operation and closed again, so "dlpagent history"
+// can read it while the agent is running.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"dlpagent/internal/history"
+	"dlpagent/internal/seal"
+)
+
+// lockTimeout is how long to wait for another process using the database
+const lockTimeout = 10 * time.Second
+
+// Record is a stored result: the common entry used by history and reports, and the
+// check-specific result it was made from
+type Record struct {
+	ID uint64 `json:"id"`
+	history.Entry
+	Data   json.RawMessage `json:"data,omitempty"`
+	HostID string          `json:"host_id,omitempty"` // description of the host the result was produced on, see AddHost
+}
+
+// EntryID returns a stable ID of the record for deduplication by the dashboard, from its check,
+// record ID, time and data, so two results with the same content still differ
+func (r Record) EntryID() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00", r.Check, r.ID, r.Timestamp.UnixNano())
+	h.Write(r.Data)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Options control retention and encryption. Records are pruned whenever new ones are added.
+type Options struct {
+	MaxAge   time.Duration // 0 keeps records of any age
+	MaxCount int           // 0 keeps any number of records
+	Key      *seal.Key     // seals the records; nil stores them in plain text
+}
+
+// Store is the result database at a path
+type Store struct {
+	path    string
+	options Options
+}
+
+// New returns the store for the database at path. The database is created on first use.
+func New(path string, opts Options) *Store {
+	return &Store{path: path, options: opts}
+}
+
+// Path returns the database file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// open opens the database, creating or migrating it as needed
+func (s *Store) open() (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result database %s: %w", s.path, err)
+	}
+	// Databases created by older agents were readable by everyone
+	os.Chmod(s.path, 0600)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate result database %s: %w", s.path, err)
+	}
+	return db, nil
+}
+
+// update runs fn in a read-write transaction
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// view runs fn in a read-only transaction
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// Add stores records in a single transaction, assigning their IDs, and applies retention
+func (s *Store) Add(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		results := tx.Bucket(bucketResults)
+		for i := range records {
+			id, err := results.NextSequence()
+			if err != nil {
+				return err
+			}
+			records[i].ID = id
+			if err := s.put(tx, records[i]); err != nil {
+				return err
+			}
+		}
+		_, err := s.prune(tx, time.Now())
+		return err
+	})
+}
+
+// Query returns the records matching q, newest first
+func (s *Store) Query(q history.Query) ([]Record, error) {
+	var records []Record
+	err := s.view(func(tx *bolt.Tx) error {
+		refs := scan(tx, q)
+		sort.Slice(refs, func(i, j int) bool {
+			return refs[i].after(refs[j])
+		})
+
+		for _, ref := range refs {
+			if q.Limit > 0 && len(records) >= q.Limit {
+				break
+			}
+			record, err := s.get(tx, ref.id)
+			if err != nil {
+				return err
+			}
+			if record != nil && q.Matches(record.Entry) {
+				records = append(records, *record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Entries returns the common entries of the records matching q, newest first
+func (s *Store) Entries(q history.Query) ([]history.Entry, error) {
+	records, err := s.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]history.Entry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, record.Entry)
+	}
+	return entries, nil
+}
+
+// Import adds the records returned by load unless an import with the same name has already
+// been done. It returns the number of records imported.
+func (s *Store) Import(name string, load func() ([]Record, error)) (int, error) {
+	imported := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		key := []byte("import:" + name)
+		if meta.Get(key) != nil {
+			return nil
+		}
+
+		records, err := load()
+		if err != nil {
+			return err
+		}
+		results := tx.Bucket(bucketResults)
+		for _, record := range records {
+			if record.ID, err = results.NextSequence(); err != nil {
+				return err
+			}
+			if err := s.put(tx, record); err != nil {
+				return err
+			}
+		}
+		if _, err := s.prune(tx, time.Now()); err != nil {
+			return err
+		}
+		imported = len(records)
+		return meta.Put(key, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+	return imported, err
+}
+
+// Item identifies a stored record without reading it
+type Item struct {
+	ID        uint64
+	Timestamp time.Time
+}
+
+// Backlog returns the records of check with an ID above after, oldest first, from the index
+func (s *Store) Backlog(check string, after uint64) ([]Item, error) {
+	var items []Item
+	err := s.view(func(tx *bolt.Tx) error {
+		items = backlog(tx, check, after)
+		return nil
+	})
+	return items, err
+}
+
+func backlog(tx *bolt.Tx, check string, after uint64) []Item {
+	var items []Item
+	for _, ref := range scan(tx, history.Query{Checks: []string{check}}) {
+		if id := binary.BigEndian.Uint64(ref.id); id > after {
+			items = append(items, Item{ID: id, Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(ref.timestamp)))})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// Pending returns up to limit records of check with an ID above after, oldest first.
+// A limit of 0 returns them all.
+func (s *Store) Pending(check string, after uint64, limit int) ([]Record, error) {
+	var records []Record
+	err := s.view(func(tx *bolt.Tx) error {
+		items := backlog(tx, check, after)
+		if limit > 0 && len(items) > limit {
+			items = items[:limit]
+		}
+		for _, item := range items {
+			record, err := s.get(tx, encodeUint(item.ID))
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				records = append(records, *record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Acknowledged returns the ID of the last record the named consumer has acknowledged, or 0
+func (s *Store) Acknowledged(name string) (uint64, error) {
+	var id uint64
+	err := s.view(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get([]byte("ack:" + name)); len(v) == 8 {
+			id = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return id, err
+}
+
+// Acknowledge records id as the last record the named consumer has processed
+func (s *Store) Acknowledge(name string, id uint64) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put([]byte("ack:"+name), encodeUint(id))
+	})
+}
+
+// AddHost stores the description of a host, once for any number of records, and returns the
+// ID records refer to it by
+func (s *Store) AddHost(data json.RawMessage) (string, error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:16])
+	err := s.update(func(tx *bolt.Tx) error {
+		hosts := tx.Bucket(bucketHosts)
+		if hosts.Get([]byte(id)) != nil {
+			return nil
+		}
+		return hosts.Put([]byte(id), s.options.Key.Seal(data))
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Host returns the host description with the given ID, or nil if there is none
+func (s *Store) Host(id string) (json.RawMessage, error) {
+	var data json.RawMessage
+	err := s.view(func(tx *bolt.Tx) error {
+		sealed := tx.Bucket(bucketHosts).Get([]byte(id))
+		if sealed == nil {
+			return nil
+		}
+		plain, err := s.options.Key.Open(sealed)
+		if err != nil {
+			return fmt.Errorf("host %s: %w", id, err)
+		}
+		// Plain data is only valid within the transaction
+		data = append(json.RawMessage(nil), plain...)
+		return nil
+	})
+	return data, err
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dlpagent/internal/history"
+)
+
+func TestEntryID(t *testing.T) {
+	now := time.Now()
+	base := Record{ID: 1, Entry: history.Entry{Timestamp: now, Check: "dlp"}, Data: json.RawMessage(`{"file_name": "card.txt"}`)}
+	same := base
+	same.Status = "failed"
+
+	tests := []struct {
+		name   string
+		record Record
+		equal  bool
+	}{
+		{"same record", same, true},
+		{"other ID", Record{ID: 2, Entry: base.Entry, Data: base.Data}, false},
+		{"other time", Record{ID: 1, Entry: history.Entry{Timestamp: now.Add(time.Second), Check: "dlp"}, Data: base.Data}, false},
+		{"other check", Record{ID: 1, Entry: history.Entry{Timestamp: now, Check: "antivirus"}, Data: base.Data}, false},
+		{"other data", Record{ID: 1, Entry: base.Entry, Data: json.RawMessage(`{"file_name": "passport.txt"}`)}, false},
+	}
+	for _, tt := range tests {
+		if equal := tt.record.EntryID() == base.EntryID(); equal != tt.equal {
+			t.Errorf("%s: same entry ID = %v, want %v", tt.name, equal, tt.equal)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package summary
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"dlpagent/internal/antivirus"
+	"dlpagent/internal/dlp"
+	"dlpagent/internal/edr"
+	"dlpagent/internal/ransomware"
+)
+
+// Exit codes of a single run. 2 is used for invalid configuration.
+const (
+	ExitEffective = 0 // every control that was tested is effective
+	ExitFailed    = 1 // at least one control failed
+	ExitError     = 3 // no control failed, but at least one check could not be run
+)
+
+// Category groups outcomes for the --fail-on policy
+type Category string
+
+const (
+	CategoryAntivirus  Category = "antivirus"
+	CategoryEDR        Category = "edr"
+	CategoryDLP        Category = "dlp"
+	CategoryRansomware Category = "ransomware"
+)
+
+// Categories lists every category, in report order
+var Categories = []Category{CategoryAntivirus, CategoryEDR, CategoryDLP, CategoryRansomware}
+
+// Status is the outcome of a single test
+type Status string
+
+const (
+	StatusEffective Status = "effective" // the control stopped the test
+	StatusFailed    Status = "failed"    // the control did not stop the test
+	StatusError     Status = "error"     // the test could not be run
+	StatusTimeout   Status = "timeout"   // the test did not finish within its deadline
+	StatusSkipped   Status = "skipped"   // the test does not apply on this platform
+)
+
+// Outcome is the result of a single test of a check
+type Outcome struct {
+	Category Category
+	Name     string
+	Status   Status
+	Detail   string
+}
+
+// Antivirus returns the outcome of an antivirus test case
+func Antivirus(result *antivirus.Result) Outcome {
+	outcome := Outcome{Category: CategoryAntivirus, Name: result.TestID, Detail: string(result.Verdict)}
+	if outcome.Name == "" {
+		outcome.Name = "download check"
+	}
+	outcome.Name = WithRoute(outcome.Name, result.Route)
+
+	switch {
+	case result.Verdict == antivirus.VerdictError:
+		outcome.Status = StatusError
+		outcome.Detail = result.StatusText
+	case result.Verdict == antivirus.VerdictTimeout:
+		outcome.Status = StatusTimeout
+		outcome.Detail = result.StatusText
+	case result.TestID != "" && result.Passed:
+		outcome.Status = StatusEffective
+	case result.TestID == "" && (result.NetworkBlocked || result.EndpointRemoved):
+		outcome.Status = StatusEffective
+	default:
+		outcome.Status = StatusFailed
+	}
+	return outcome
+}
+
+// EDR returns the outcome of an EDR behaviour simulation
+func EDR(result *edr.Result) Outcome {
+	outcome := Outcome{Category: CategoryEDR, Name: result.TechniqueID + " " + result.Name, Detail: string(result.Verdict)}
+	switch result.Verdict {
+	case edr.VerdictPrevented:
+		outcome.Status = StatusEffective
+	case edr.VerdictSkipped:
+		outcome.Status = StatusSkipped
+		outcome.Detail = result.StatusText
+	case edr.VerdictExecuted:
+		outcome.Status = StatusFailed
+	default:
+		outcome.Status = StatusError
+		outcome.Detail = result.StatusText
+	}
+	return outcome
+}
+
+// WithRoute names a test sent on a compared route, e.g. "eicar via direct"
+func WithRoute(name, route string) string {
+	if route == "" {
+		return name
+	}
+	return name + " via " + route
+}
+
+// DLP returns the outcome of the DLP check of a single file
+func DLP(file string, result *dlp.Result) Outcome {
+	outcome := Outcome{Category: CategoryDLP, Name: WithRoute(file, result.Route), Status: StatusFailed, Detail: result.StatusText}
+	switch {
+	case result.TimedOut:
+		outcome.Status = StatusTimeout
+	case result.Error:
+		outcome.Status = StatusError
+	case result.IsDLPActive:
+		outcome.Status = StatusEffective
+	}
+	return outcome
+}
+
+// Ransomware returns the outcome of the ransomware simulation
+func Ransomware(result *ransomware.Result) Outcome {
+	outcome := Outcome{Category: CategoryRansomware, Name: "ransomware simulation", Detail: string(result.Verdict)}
+	switch {
+	case result.Verdict == ransomware.VerdictError:
+		outcome.Status = StatusError
+		outcome.Detail = result.StatusText
+	case result.IsEDRActive():
+		outcome.Status = StatusEffective
+	default:
+		outcome.Status = StatusFailed
+	}
+	return outcome
+}
+
+// Error returns the outcome of a check that could not be run
+func Error(category Category, name string, err error) Outcome {
+	return Outcome{Category: category, Name: name, Status: StatusError, Detail: err.Error()}
+}
+
+// Timeout returns the outcome of a test that did not finish within its deadline
+func Timeout(category Category, name string, err error) Outcome {
+	return Outcome{Category: category, Name: name, Status: StatusTimeout, Detail: err.Error()}
+}
+
+// Policy selects the categories whose outcomes decide the exit code
+type Policy map[Category]bool
+
+// ParsePolicy parses --fail-on values. Empty or "all" selects every category.
+func ParsePolicy(values []string) (Policy, error) {
+	policy := Policy{}
+	if len(values) == 0 {
+		values = []string{"all"}
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "all" {
+			for _, category := range Categories {
+				policy[category] = true
+			}
+			continue
+		}
+
+		known := false
+		for _, category := range Categories {
+			if Category(value) == category {
+				policy[category] = true
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown category %q (expected all, antivirus, edr, dlp or ransomware)", value)
+		}
+	}
+	return policy, nil
+}
+
+// Summary collects the outcomes of a run
+type Summary struct {
+	Outcomes []Outcome
+}
+
+func (s *Summary) Add(outcomes ...Outcome) {
+	s.Outcomes = append(s.Outcomes, outcomes...)
+}
+
+// ExitCode returns the exit code for the outcomes in the categories selected by policy.
+// A failed control takes precedence over an error or timeout.
+func (s *Summary) ExitCode(policy Policy) int {
+	code := ExitEffective
+	for _, outcome := range s.Outcomes {
+		if !policy[outcome.Category] {
+			continue
+		}
+		switch outcome.Status {
+		case StatusFailed:
+			return ExitFailed
+		case StatusError, StatusTimeout:
+			code = ExitError
+		}
+	}
+	return code
+}
+
+// Print writes the outcome counts per category followed by every test that did not pass
+func (s *Summary) Print(w io.Writer, policy Policy) {
+	counts := map[Category]map[Status]int{}
+	for _, outcome := range s.Outcomes {
+		if counts[outcome.Category] == nil {
+			counts[outcome.Category] = map[Status]int{}
+		}
+		counts[outcome.Category][outcome.Status]++
+	}
+
+	fmt.Fprintln(w, "\n=== Summary ===")
+	for _, category := range Categories {
+		c, ok := counts[category]
+		if !ok {
+			continue
+		}
+		note := ""
+		if !policy[category] {
+			note = " (not in --fail-on)"
+		}
+		fmt.Fprintf(w, "%-11s effective: %d, failed: %d, error: %d, timeout: %d, skipped: %d%s\n", category+":",
+			c[StatusEffective], c[StatusFailed], c[StatusError], c[StatusTimeout], c[StatusSkipped], note)
+	}
+
+	problems := make([]Outcome, 0)
+	for _, outcome := range s.Outcomes {
+		if outcome.Status == StatusFailed || outcome.Status == StatusError || outcome.Status == StatusTimeout {
+			problems = append(problems, outcome)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Status < problems[j].Status })
+	for _, outcome := range problems {
+		mark := "❌"
+		switch outcome.Status {
+		case StatusError:
+			mark = "⚠️ "
+		case StatusTimeout:
+			mark = "⏱️ "
+		}
+		fmt.Fprintf(w, "%s %s %s: %s", mark, outcome.Category, outcome.Name, outcome.Status)
+		if outcome.Detail != "" {
+			fmt.Fprintf(w, " (%s)", outcome.Detail)
+		}
+		fmt.Fprintln(w)
+	}
+
+	switch s.ExitCode(policy) {
+	case ExitEffective:
+		fmt.Fprintln(w, "✅ All tested controls are effective")
+	case ExitFailed:
+		fmt.Fprintln(w, "❌ At least one control failed")
+	case ExitError:
+		fmt.Fprintln(w, "⚠️  Some checks could not be run or timed out")
+	}
+}
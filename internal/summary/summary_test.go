@@ -0,0 +1,79 @@
+package summary
+
+import (
+	"errors"
+	"testing"
+
+	"dlpagent/internal/dlp"
+)
+
+func TestExitCode(t *testing.T) {
+	failed := Outcome{Category: CategoryDLP, Status: StatusFailed}
+	errored := Outcome{Category: CategoryAntivirus, Status: StatusError}
+	timedOut := Outcome{Category: CategoryEDR, Status: StatusTimeout}
+	effective := Outcome{Category: CategoryRansomware, Status: StatusEffective}
+	skipped := Outcome{Category: CategoryEDR, Status: StatusSkipped}
+
+	tests := []struct {
+		name     string
+		outcomes []Outcome
+		failOn   []string
+		want     int
+	}{
+		{"nothing run", nil, nil, ExitEffective},
+		{"effective and skipped", []Outcome{effective, skipped}, nil, ExitEffective},
+		{"error", []Outcome{effective, errored}, nil, ExitError},
+		{"timeout", []Outcome{timedOut}, nil, ExitError},
+		{"failure after an error", []Outcome{errored, timedOut, failed}, nil, ExitFailed},
+		{"failure before an error", []Outcome{failed, errored}, nil, ExitFailed},
+		{"failure outside --fail-on", []Outcome{failed, effective}, []string{"antivirus", "ransomware"}, ExitEffective},
+		{"error outside --fail-on", []Outcome{errored, failed}, []string{"edr"}, ExitEffective},
+		{"failure in --fail-on", []Outcome{errored, failed}, []string{"DLP"}, ExitFailed},
+	}
+	for _, tt := range tests {
+		policy, err := ParsePolicy(tt.failOn)
+		if err != nil {
+			t.Fatalf("%s: ParsePolicy(%q) = %v", tt.name, tt.failOn, err)
+		}
+		s := Summary{Outcomes: tt.outcomes}
+		if got := s.ExitCode(policy); got != tt.want {
+			t.Errorf("%s: ExitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if _, err := ParsePolicy([]string{"antivirus", "firewall"}); err == nil {
+		t.Error("ParsePolicy(firewall) = nil error, want an unknown category")
+	}
+	policy, err := ParsePolicy([]string{" all "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, category := range Categories {
+		if !policy[category] {
+			t.Errorf("ParsePolicy(all) does not select %s", category)
+		}
+	}
+}
+
+func TestDLP(t *testing.T) {
+	tests := []struct {
+		name   string
+		result dlp.Result
+		want   Status
+	}{
+		{"blocked", dlp.Result{IsDLPActive: true}, StatusEffective},
+		{"sent", dlp.Result{}, StatusFailed},
+		{"error", dlp.Result{Error: true}, StatusError},
+		{"timeout", dlp.Result{TimedOut: true}, StatusTimeout},
+	}
+	for _, tt := range tests {
+		if got := DLP("test_passport.txt", &tt.result).Status; got != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := Error(CategoryDLP, "settings", errors.New("unreachable")); got.Status != StatusError || got.Detail != "unreachable" {
+		t.Errorf("Error() = %+v", got)
+	}
+}